@@ -0,0 +1,220 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"log"
+	"math/big"
+	"math/bits"
+	mrand "math/rand"
+	"time"
+
+	"github.com/cloudflare/circl/group"
+)
+
+// ------------------------
+// Batch Verification
+// ------------------------
+
+// Bits of the random weights of a batch. A bad batch passes with
+// probability about 2^-batchWeightBits.
+const batchWeightBits = 128
+
+var batchWeightBound = new(big.Int).Lsh(big.NewInt(1), batchWeightBits)
+
+// MultiScalarMult computes sum scalars[i]*points[i] with Pippenger's bucket
+// method. Each window costs one addition per point plus 2^c bucket
+// additions, instead of a full scalar multiplication per point. That pays
+// off when additions are cheap next to a scalar multiplication, as on
+// ristretto255, less so with the affine additions of circl's NIST curves.
+func MultiScalarMult(g group.Group, points []group.Element, scalars []*big.Int) group.Element {
+	maxBits := 0
+	for _, s := range scalars {
+		if s.BitLen() > maxBits {
+			maxBits = s.BitLen()
+		}
+	}
+	c := bits.Len(uint(len(points))) - 2
+	if c < 2 {
+		c = 2
+	}
+
+	result := g.Identity()
+	buckets := make([]group.Element, 1<<c-1)
+	for w := (maxBits + c - 1) / c; w > 0; w-- {
+		for i := 0; i < c; i++ {
+			result.Dbl(result)
+		}
+		for i := range buckets {
+			buckets[i] = nil
+		}
+		for i, s := range scalars {
+			digit := 0
+			for b := c - 1; b >= 0; b-- {
+				digit = digit<<1 | int(s.Bit((w-1)*c+b))
+			}
+			if digit == 0 {
+				continue
+			}
+			if buckets[digit-1] == nil {
+				buckets[digit-1] = points[i].Copy()
+			} else {
+				buckets[digit-1].Add(buckets[digit-1], points[i])
+			}
+		}
+		// sum_d d*bucket_d as a running sum from the top bucket down
+		running, sum := g.Identity(), g.Identity()
+		for d := len(buckets) - 1; d >= 0; d-- {
+			if buckets[d] != nil {
+				running.Add(running, buckets[d])
+			}
+			sum.Add(sum, running)
+		}
+		result.Add(result, sum)
+	}
+	return result
+}
+
+// batchItem is a coded symbol that passed the shape checks
+type batchItem struct {
+	index    int
+	combined group.Element // Sum of the commitments at the symbol's positions
+	value    *big.Int
+	r        *big.Int
+}
+
+// VerifyBatch checks many coded symbols with one random linear combination
+//
+//	sum a_j*sum(C_pos) == (sum a_j*value_j)*G + (sum a_j*r_j)*H
+//
+// where the left side is a single multi-exponentiation. When the batch
+// fails it is bisected until the bad symbols are isolated. It returns the
+// value of every good symbol, nil for bad ones, and the indices of the bad
+// symbols.
+func (v *SymbolVerifier) VerifyBatch(symbols []*CodedSymbol) ([]*big.Int, []int) {
+	values := make([]*big.Int, len(symbols))
+	var bad []int
+	items := make([]batchItem, 0, len(symbols))
+	for i, cs := range symbols {
+		value, r, ok := v.parse(cs)
+		if !ok {
+			bad = append(bad, i)
+			continue
+		}
+		items = append(items, batchItem{
+			index:    i,
+			combined: CombineCommitments(v.params, v.commitments, cs.Positions),
+			value:    value,
+			r:        r,
+		})
+	}
+	bad = append(bad, v.bisect(items)...)
+
+	isBad := make(map[int]bool, len(bad))
+	for _, i := range bad {
+		isBad[i] = true
+	}
+	for _, item := range items {
+		if !isBad[item.index] {
+			values[item.index] = item.value
+		}
+	}
+	return values, bad
+}
+
+// bisect returns the indices of the bad items, checking halves of a failed
+// batch with fresh weights
+func (v *SymbolVerifier) bisect(items []batchItem) []int {
+	if len(items) == 0 || v.checkBatch(items) {
+		return nil
+	}
+	if len(items) == 1 {
+		return []int{items[0].index}
+	}
+	mid := len(items) / 2
+	return append(v.bisect(items[:mid]), v.bisect(items[mid:])...)
+}
+
+// checkBatch tests one random linear combination of the items
+func (v *SymbolVerifier) checkBatch(items []batchItem) bool {
+	if len(items) == 1 {
+		item := items[0]
+		return item.combined.IsEqual(v.commit(item.value, item.r))
+	}
+	points := make([]group.Element, len(items))
+	weights := make([]*big.Int, len(items))
+	valueSum := big.NewInt(0)
+	rSum := big.NewInt(0)
+	for j, item := range items {
+		a, err := rand.Int(rand.Reader, batchWeightBound)
+		if err != nil {
+			panic(err)
+		}
+		points[j] = item.combined
+		weights[j] = a
+		valueSum.Add(valueSum, new(big.Int).Mul(a, item.value))
+		rSum.Add(rSum, new(big.Int).Mul(a, item.r))
+	}
+	lhs := MultiScalarMult(v.params.Group, points, weights)
+	return lhs.IsEqual(v.commit(valueSum, rSum))
+}
+
+// runBatch compares per-symbol and batched verification of coded symbols
+// for each batch size, with and without one corrupted symbol in the batch
+func runBatch(engine *CommitmentEngine, numTxs int, soliton RobustSolitonParams, precode bool, mode CommitmentRandomness, sizes []int) {
+	params := engine.Params
+	data, err := SerializedBlock(numTxs)
+	if err != nil {
+		panic(err)
+	}
+	block := NewSourceBlock(engine, 0, data, soliton, precode, mode)
+	var pc *Precode
+	if precode {
+		pc = NewPrecode(len(block.Header.Commitments))
+	}
+	verifier, err := NewSymbolVerifier(engine, block.Header, pc)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("# group=%s randomness=%s precode=%v K=%d\n", GroupName(params.Group), mode, precode, len(block.Header.Commitments))
+	fmt.Println("batch_size,corrupted,per_symbol_ms,batched_ms,speedup,found")
+	for _, size := range sizes {
+		symbols := make([]*CodedSymbol, size)
+		for i := range symbols {
+			symbols[i] = block.CodedSymbol(params)
+		}
+		for _, corrupted := range []bool{false, true} {
+			want := -1
+			if corrupted {
+				want = mrand.Intn(size)
+				tampered := *symbols[want]
+				tampered.Value = addOne(params, tampered.Value)
+				symbols[want] = &tampered
+			}
+
+			startTime := time.Now()
+			var perSymbolBad []int
+			for i, cs := range symbols {
+				if _, ok := verifier.Verify(cs); !ok {
+					perSymbolBad = append(perSymbolBad, i)
+				}
+			}
+			perSymbol := time.Since(startTime)
+
+			startTime = time.Now()
+			_, batchBad := verifier.VerifyBatch(symbols)
+			batched := time.Since(startTime)
+
+			if len(batchBad) != len(perSymbolBad) || (corrupted && batchBad[0] != want) {
+				log.Fatalf("Batch of %d flagged %v, per symbol flagged %v", size, batchBad, perSymbolBad)
+			}
+			found := ""
+			if corrupted {
+				found = fmt.Sprint(batchBad[0])
+			}
+			fmt.Printf("%d,%v,%.3f,%.3f,%.2f,%s\n", size, corrupted,
+				perSymbol.Seconds()*1000, batched.Seconds()*1000, perSymbol.Seconds()/batched.Seconds(), found)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// Transaction and Block mirror the types used by the sync simulators so the
+// LT codec can be run on the same serialized blocks.
+type Transaction struct {
+	ID        string // Unique identifier for the transaction
+	Content   string // Content or data of the transaction
+	Signature string // Cryptographic signature to verify the transaction's authenticity
+	Timestamp int64  // Unix timestamp for when the transaction was created
+}
+
+type Block struct {
+	ID           int           // Unique identifier for the block
+	Transactions []Transaction // List of transactions included in the block
+	Hash         string        // Cryptographic hash of the block's contents
+	PreviousHash string        // Hash of the previous block to ensure integrity in the chain
+	Nonce        int           // Nonce used for mining/block creation
+	Timestamp    int64         // Unix timestamp for when the block was created
+}
+
+func GenerateTransactions(num int) []Transaction {
+	var transactions []Transaction
+	for i := 0; i < num; i++ {
+		txn := Transaction{
+			ID:        strconv.Itoa(i), // Simple incremental IDs
+			Content:   "Data for transaction " + strconv.Itoa(i),
+			Signature: GenerateSignature("Data for transaction " + strconv.Itoa(i)),
+			Timestamp: time.Now().Unix(),
+		}
+		transactions = append(transactions, txn)
+	}
+	return transactions
+}
+
+func GenerateSignature(data string) string {
+	hasher := sha256.New()
+	hasher.Write([]byte(data))
+	return hex.EncodeToString(hasher.Sum(nil))
+}
+
+func GenerateBlockHash(block Block) string {
+	hasher := sha256.New()
+	hasher.Write([]byte(block.PreviousHash))
+	for _, txn := range block.Transactions {
+		hasher.Write([]byte(txn.ID + txn.Content + txn.Signature))
+	}
+	hasher.Write([]byte(strconv.Itoa(block.Nonce)))
+	hasher.Write([]byte(strconv.FormatInt(block.Timestamp, 10)))
+	return hex.EncodeToString(hasher.Sum(nil))
+}
+
+// SerializedBlock builds a block of numTxs transactions and returns its JSON
+// encoding, the same bytes the simulators erasure code.
+func SerializedBlock(numTxs int) ([]byte, error) {
+	block := &Block{
+		ID:           0,
+		PreviousHash: "",
+		Transactions: GenerateTransactions(numTxs),
+		Nonce:        0,
+		Timestamp:    time.Now().Unix(),
+		Hash:         "",
+	}
+	block.Hash = GenerateBlockHash(*block)
+	return json.Marshal(block)
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	mrand "math/rand"
+	"os"
+)
+
+// ------------------------
+// Byte-oriented LT Codec
+// ------------------------
+
+// ByteField selects how source blocks are combined into a coded symbol
+type ByteField int
+
+const (
+	FieldXOR   ByteField = iota // Symbols are the XOR of their source blocks
+	FieldGF256                  // Symbols are random GF(2^8) linear combinations
+)
+
+func (f ByteField) String() string {
+	switch f {
+	case FieldXOR:
+		return "xor"
+	case FieldGF256:
+		return "gf256"
+	}
+	return fmt.Sprintf("ByteField(%d)", int(f))
+}
+
+// ByteSymbol is a coded symbol over fixed-size byte blocks
+type ByteSymbol struct {
+	Data         []byte
+	Positions    []int
+	Coefficients []byte // GF(2^8) coefficient per position, nil in XOR mode
+}
+
+// ByteCodec encodes and decodes fixed-size byte blocks without big.Int
+type ByteCodec struct {
+	BlockSize    int       // Size of each source block in bytes
+	Field        ByteField // Arithmetic used to combine blocks
+	Inactivation bool      // Fall back to inactivation decoding when peeling stalls
+	Precode      bool      // Put a Raptor-style LDPC precode in front of the LT stage
+	precode      *Precode  // Precode of the last K, built once and reused across trials
+}
+
+// precodeFor returns the precode for K source blocks
+func (c *ByteCodec) precodeFor(K int) *Precode {
+	if c.precode == nil || c.precode.K != K {
+		c.precode = NewPrecode(K)
+	}
+	return c.precode
+}
+
+// Split cuts data into BlockSize blocks, zero padding the last one
+func (c *ByteCodec) Split(data []byte) [][]byte {
+	K := (len(data) + c.BlockSize - 1) / c.BlockSize
+	blocks := make([][]byte, K)
+	for i := 0; i < K; i++ {
+		block := make([]byte, c.BlockSize)
+		copy(block, data[i*c.BlockSize:])
+		blocks[i] = block
+	}
+	return blocks
+}
+
+// JoinBlocks concatenates decoded blocks and strips the padding
+func JoinBlocks(blocks [][]byte, length int) []byte {
+	data := make([]byte, 0, length)
+	for _, block := range blocks {
+		data = append(data, block...)
+	}
+	return data[:length]
+}
+
+// Intermediate returns the blocks the LT stage encodes, i.e. the source
+// blocks followed by the precode parity blocks when the precode is enabled
+func (c *ByteCodec) Intermediate(blocks [][]byte) [][]byte {
+	if !c.Precode {
+		return blocks
+	}
+	return c.precodeFor(len(blocks)).ExtendBlocks(blocks)
+}
+
+// EncodeSymbol produces one coded symbol from the intermediate blocks
+func (c *ByteCodec) EncodeSymbol(blocks [][]byte, sampler *DegreeSampler) ByteSymbol {
+	d := sampler.Sample()
+	positions := samplePositions(len(blocks), d)
+	symbol := ByteSymbol{
+		Data:      make([]byte, c.BlockSize),
+		Positions: positions,
+	}
+	if c.Field == FieldGF256 {
+		symbol.Coefficients = make([]byte, d)
+		for i := range symbol.Coefficients {
+			symbol.Coefficients[i] = byte(mrand.Intn(255) + 1)
+		}
+	}
+	for i, pos := range positions {
+		coef := byte(1)
+		if symbol.Coefficients != nil {
+			coef = symbol.Coefficients[i]
+		}
+		gfMulAdd(symbol.Data, blocks[pos], coef)
+	}
+	return symbol
+}
+
+// Encode produces numEncodedSymbols coded symbols from the source blocks.
+// The degree distribution must cover the intermediate blocks.
+func (c *ByteCodec) Encode(blocks [][]byte, numEncodedSymbols int, robust []float64) []ByteSymbol {
+	intermediate := c.Intermediate(blocks)
+	sampler := NewDegreeSampler(robust)
+	symbols := make([]ByteSymbol, numEncodedSymbols)
+	for i := range symbols {
+		symbols[i] = c.EncodeSymbol(intermediate, sampler)
+	}
+	return symbols
+}
+
+// DegreeDistribution returns the LT degree distribution for K source
+// blocks: robust soliton for plain LT, the Raptor one behind a precode
+func (c *ByteCodec) DegreeDistribution(K int, params RobustSolitonParams) []float64 {
+	if c.Precode {
+		return RaptorDegreeDistribution(c.precodeFor(K).L())
+	}
+	params.K = K
+	return RobustSolitonDistribution(params)
+}
+
+// NewDecoder returns an incremental peeling decoder for K source blocks
+func (c *ByteCodec) NewDecoder(K int) *PeelingDecoder[byte, []byte] {
+	var decoder *PeelingDecoder[byte, []byte]
+	if c.Precode {
+		zero := func() []byte { return make([]byte, c.BlockSize) }
+		decoder = NewPrecodedDecoder[byte, []byte](gf256Field{}, c.precodeFor(K), zero)
+	} else {
+		decoder = NewPeelingDecoder[byte, []byte](gf256Field{}, K)
+	}
+	decoder.Inactivation = c.Inactivation
+	return decoder
+}
+
+// Peeling decoding over bytes. The input symbols are left untouched.
+func (c *ByteCodec) Decode(symbols []ByteSymbol, K int) ([][]byte, DecodeStats, bool) {
+	decoder := c.NewDecoder(K)
+	for _, s := range symbols {
+		if decoder.AddSymbol(s.Positions, s.Coefficients, s.Data) {
+			break
+		}
+	}
+	recovered, success := decoder.Result()
+	if !success {
+		return nil, decoder.Stats, false
+	}
+	return recovered[:K], decoder.Stats, true
+}
+
+// readMessageFromFile reads any file, e.g. a serialized Block, as raw bytes
+func readMessageFromFile(filename string) ([]byte, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("input file %s is empty", filename)
+	}
+	return data, nil
+}
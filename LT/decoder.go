@@ -0,0 +1,459 @@
+package main
+
+import (
+	"math/big"
+	"sort"
+)
+
+// ------------------------
+// Symbol Arithmetic
+// ------------------------
+
+// symbolField is the arithmetic the decoder needs over coded symbols. S is
+// the type of a coefficient and V the type of a symbol value.
+type symbolField[S, V any] interface {
+	zero() S
+	one() S
+	isZero(c S) bool
+	add(a, b S) S
+	mul(a, b S) S
+	neg(c S) S
+	inv(c S) S
+	clone(v V) V
+	// subScaled sets dst = dst - c*src
+	subScaled(dst, src V, c S)
+	// scale sets v = c*v
+	scale(v V, c S)
+}
+
+// zpField operates on big.Int symbols modulo p
+type zpField struct {
+	p *big.Int
+}
+
+var (
+	bigZero = big.NewInt(0)
+	bigOne  = big.NewInt(1)
+)
+
+func (f zpField) zero() *big.Int { return bigZero }
+
+func (f zpField) one() *big.Int { return bigOne }
+
+func (f zpField) isZero(c *big.Int) bool { return c.Sign() == 0 }
+
+func (f zpField) add(a, b *big.Int) *big.Int {
+	sum := new(big.Int).Add(a, b)
+	return sum.Mod(sum, f.p)
+}
+
+func (f zpField) mul(a, b *big.Int) *big.Int {
+	prod := new(big.Int).Mul(a, b)
+	return prod.Mod(prod, f.p)
+}
+
+func (f zpField) neg(c *big.Int) *big.Int {
+	n := new(big.Int).Neg(c)
+	return n.Mod(n, f.p)
+}
+
+func (f zpField) inv(c *big.Int) *big.Int { return new(big.Int).ModInverse(c, f.p) }
+
+func (f zpField) clone(v *big.Int) *big.Int { return new(big.Int).Set(v) }
+
+func (f zpField) subScaled(dst, src, c *big.Int) {
+	if c.Cmp(bigOne) == 0 {
+		dst.Sub(dst, src)
+	} else {
+		dst.Sub(dst, new(big.Int).Mul(c, src))
+	}
+	dst.Mod(dst, f.p)
+}
+
+func (f zpField) scale(v, c *big.Int) {
+	v.Mul(v, c)
+	v.Mod(v, f.p)
+}
+
+// gf256Field operates on byte blocks, coefficients are GF(2^8) elements. In
+// XOR mode every coefficient is 1, so all arithmetic stays inside GF(2).
+type gf256Field struct{}
+
+func (gf256Field) zero() byte { return 0 }
+
+func (gf256Field) one() byte { return 1 }
+
+func (gf256Field) isZero(c byte) bool { return c == 0 }
+
+func (gf256Field) add(a, b byte) byte { return a ^ b }
+
+func (gf256Field) mul(a, b byte) byte { return gfMul(a, b) }
+
+func (gf256Field) neg(c byte) byte { return c }
+
+func (gf256Field) inv(c byte) byte { return gfInv(c) }
+
+func (gf256Field) clone(v []byte) []byte { return append([]byte(nil), v...) }
+
+func (gf256Field) subScaled(dst, src []byte, c byte) { gfMulAdd(dst, src, c) }
+
+func (gf256Field) scale(v []byte, c byte) { gfScale(v, c) }
+
+// ------------------------
+// Peeling Decoder
+// ------------------------
+
+// peelSymbol is a received symbol with the resolved positions already
+// substituted out. degree counts the positions that are still unknown, and
+// inactive holds the coefficients picked up from inactivated source symbols,
+// keyed by inactivation index.
+type peelSymbol[S, V any] struct {
+	value        V
+	positions    []int
+	coefficients []S
+	degree       int
+	inactive     map[int]S
+}
+
+// edge links a message index to the slot it occupies in a symbol
+type edge struct {
+	symbol int32
+	slot   int32
+}
+
+// pivotRow is a normalized equation over the inactivated source symbols.
+// Its lowest index has coefficient one.
+type pivotRow[S, V any] struct {
+	coefficients map[int]S
+	value        V
+}
+
+// DecodeStats summarizes a decoding run
+type DecodeStats struct {
+	K               int // Number of source symbols
+	SymbolsReceived int // Symbols handed to the decoder
+	SymbolsNeeded   int // Symbols received when the last source symbol was recovered, 0 if not done
+	Inactivated     int // Source symbols inactivated when peeling stalled
+}
+
+// Overhead is the fraction of symbols needed beyond K
+func (s DecodeStats) Overhead() float64 {
+	return float64(s.SymbolsNeeded-s.K) / float64(s.K)
+}
+
+// PeelingDecoder is a belief-propagation decoder that accepts symbols as
+// they arrive. Each message index keeps the list of symbols it still appears
+// in, so recovering it touches only those symbols and the total work is
+// linear in the number of edges.
+//
+// With Inactivation set, a stalled ripple no longer waits for more symbols
+// once K have arrived. The decoder inactivates a source symbol of the lowest
+// degree pending symbol, treats it as a free variable and keeps peeling.
+// Recovered values become affine in the inactivated symbols, symbols that
+// run out of unknowns turn into equations over them, and those equations are
+// solved by Gaussian elimination as they arrive.
+type PeelingDecoder[S, V any] struct {
+	Inactivation bool // Inactivate source symbols when peeling stalls
+	Stats        DecodeStats
+
+	field       symbolField[S, V]
+	symbols     []peelSymbol[S, V]
+	adjacency   [][]edge
+	queue       []int
+	remaining   int // Source symbols neither recovered nor inactivated
+	constraints int // Constraints added next to the received symbols
+	complete    bool
+
+	// Resolved source symbols. A recovered value equals
+	// recovered[pos] + sum of recoveredInactive[pos][j] * z_j where z_j
+	// is the j-th inactivated source symbol.
+	resolved          []bool
+	recovered         []V
+	recoveredInactive []map[int]S
+	inactiveIndex     []int
+	inactive          []int
+	pivots            map[int]*pivotRow[S, V]
+}
+
+func NewPeelingDecoder[S, V any](field symbolField[S, V], K int) *PeelingDecoder[S, V] {
+	inactiveIndex := make([]int, K)
+	for i := range inactiveIndex {
+		inactiveIndex[i] = -1
+	}
+	return &PeelingDecoder[S, V]{
+		Stats:             DecodeStats{K: K},
+		field:             field,
+		adjacency:         make([][]edge, K),
+		remaining:         K,
+		resolved:          make([]bool, K),
+		recovered:         make([]V, K),
+		recoveredInactive: make([]map[int]S, K),
+		inactiveIndex:     inactiveIndex,
+		pivots:            make(map[int]*pivotRow[S, V]),
+	}
+}
+
+// coefficient returns the coefficient of the given slot, nil coefficients mean one
+func (d *PeelingDecoder[S, V]) coefficient(s *peelSymbol[S, V], slot int) S {
+	if s.coefficients == nil {
+		return d.field.one()
+	}
+	return s.coefficients[slot]
+}
+
+// accumulate adds c to m[j], dropping entries that cancel out
+func (d *PeelingDecoder[S, V]) accumulate(m map[int]S, j int, c S) map[int]S {
+	if m == nil {
+		m = make(map[int]S)
+	}
+	sum := c
+	if prev, ok := m[j]; ok {
+		sum = d.field.add(prev, c)
+	}
+	if d.field.isZero(sum) {
+		delete(m, j)
+	} else {
+		m[j] = sum
+	}
+	return m
+}
+
+// substitute removes a resolved source symbol with coefficient c from s
+func (d *PeelingDecoder[S, V]) substitute(s *peelSymbol[S, V], pos int, c S) {
+	if j := d.inactiveIndex[pos]; j >= 0 {
+		s.inactive = d.accumulate(s.inactive, j, c)
+		return
+	}
+	d.field.subScaled(s.value, d.recovered[pos], c)
+	for j, b := range d.recoveredInactive[pos] {
+		s.inactive = d.accumulate(s.inactive, j, d.field.mul(c, b))
+	}
+}
+
+// AddSymbol feeds one received symbol to the decoder and peels as far as
+// possible. The inputs are copied, the caller keeps ownership of them.
+// It reports whether every source symbol has been recovered.
+func (d *PeelingDecoder[S, V]) AddSymbol(positions []int, coefficients []S, value V) bool {
+	d.Stats.SymbolsReceived++
+	return d.add(positions, coefficients, value)
+}
+
+// AddConstraint feeds a known linear relation between source symbols, such
+// as a precode check, without counting it as a received symbol
+func (d *PeelingDecoder[S, V]) AddConstraint(positions []int, coefficients []S, value V) bool {
+	d.constraints++
+	return d.add(positions, coefficients, value)
+}
+
+func (d *PeelingDecoder[S, V]) add(positions []int, coefficients []S, value V) bool {
+	if d.complete {
+		return true
+	}
+
+	// Keep copies, the caller may reuse its slices
+	positions = append([]int(nil), positions...)
+	s := peelSymbol[S, V]{
+		value:        d.field.clone(value),
+		positions:    positions,
+		coefficients: append([]S(nil), coefficients...),
+	}
+	for slot, pos := range positions {
+		if d.resolved[pos] {
+			d.substitute(&s, pos, d.coefficient(&s, slot))
+		} else {
+			s.degree++
+		}
+	}
+	if s.degree == 0 {
+		d.addEquation(s.inactive, s.value)
+		d.checkComplete()
+		return d.complete
+	}
+
+	idx := len(d.symbols)
+	d.symbols = append(d.symbols, s)
+	for slot, pos := range positions {
+		if !d.resolved[pos] {
+			d.adjacency[pos] = append(d.adjacency[pos], edge{symbol: int32(idx), slot: int32(slot)})
+		}
+	}
+	if s.degree == 1 {
+		d.queue = append(d.queue, idx)
+	}
+
+	d.peel()
+	if d.Inactivation && d.Stats.SymbolsReceived+d.constraints >= len(d.resolved) {
+		for d.remaining > 0 && d.inactivateOne() {
+			d.peel()
+		}
+	}
+	d.checkComplete()
+	return d.complete
+}
+
+// peel releases degree-one symbols until the ripple is empty
+func (d *PeelingDecoder[S, V]) peel() {
+	for len(d.queue) > 0 {
+		idx := d.queue[len(d.queue)-1]
+		d.queue = d.queue[:len(d.queue)-1]
+		s := &d.symbols[idx]
+		if s.degree != 1 {
+			continue
+		}
+
+		// Find the one position that is still unknown
+		slot := 0
+		for d.resolved[s.positions[slot]] {
+			slot++
+		}
+		pos := s.positions[slot]
+		cInv := d.field.inv(d.coefficient(s, slot))
+		d.field.scale(s.value, cInv)
+		var inactive map[int]S
+		for j, a := range s.inactive {
+			inactive = d.accumulate(inactive, j, d.field.neg(d.field.mul(cInv, a)))
+		}
+		s.degree = 0
+		s.inactive = nil
+		d.recovered[pos] = s.value
+		d.recoveredInactive[pos] = inactive
+		d.resolve(pos)
+	}
+}
+
+// inactivateOne picks an unknown source symbol of the lowest degree pending
+// symbol and turns it into a free variable. It reports false when no pending
+// symbol is left to inactivate from.
+func (d *PeelingDecoder[S, V]) inactivateOne() bool {
+	best := -1
+	for i := range d.symbols {
+		if deg := d.symbols[i].degree; deg > 0 && (best < 0 || deg < d.symbols[best].degree) {
+			best = i
+			if deg <= 2 {
+				break
+			}
+		}
+	}
+	if best < 0 {
+		return false
+	}
+	s := &d.symbols[best]
+	for _, pos := range s.positions {
+		if !d.resolved[pos] {
+			d.inactiveIndex[pos] = len(d.inactive)
+			d.inactive = append(d.inactive, pos)
+			d.Stats.Inactivated++
+			d.resolve(pos)
+			return true
+		}
+	}
+	return false
+}
+
+// resolve marks pos as recovered or inactivated and substitutes it into its
+// neighbours
+func (d *PeelingDecoder[S, V]) resolve(pos int) {
+	d.resolved[pos] = true
+	d.remaining--
+
+	for _, e := range d.adjacency[pos] {
+		other := &d.symbols[e.symbol]
+		if other.degree == 0 {
+			continue
+		}
+		d.substitute(other, pos, d.coefficient(other, int(e.slot)))
+		other.degree--
+		switch other.degree {
+		case 1:
+			d.queue = append(d.queue, int(e.symbol))
+		case 0:
+			d.addEquation(other.inactive, other.value)
+			other.inactive = nil
+		}
+	}
+	d.adjacency[pos] = nil
+}
+
+// addEquation reduces an equation over the inactivated source symbols
+// against the current pivots and keeps it if it adds rank
+func (d *PeelingDecoder[S, V]) addEquation(coefficients map[int]S, value V) {
+	for len(coefficients) > 0 {
+		lead := -1
+		for j := range coefficients {
+			if lead < 0 || j < lead {
+				lead = j
+			}
+		}
+		c := coefficients[lead]
+		pivot, ok := d.pivots[lead]
+		if !ok {
+			cInv := d.field.inv(c)
+			for j, a := range coefficients {
+				coefficients[j] = d.field.mul(a, cInv)
+			}
+			d.field.scale(value, cInv)
+			d.pivots[lead] = &pivotRow[S, V]{coefficients: coefficients, value: value}
+			return
+		}
+		for j, a := range pivot.coefficients {
+			coefficients = d.accumulate(coefficients, j, d.field.neg(d.field.mul(c, a)))
+		}
+		d.field.subScaled(value, pivot.value, c)
+	}
+	// The equation was a combination of earlier ones, nothing to keep
+}
+
+// checkComplete finishes decoding once every source symbol is resolved and
+// the inactivated ones are pinned down by full rank equations
+func (d *PeelingDecoder[S, V]) checkComplete() {
+	if d.complete || d.remaining > 0 || len(d.pivots) < len(d.inactive) {
+		return
+	}
+
+	// Back substitution, highest pivot first
+	z := make([]V, len(d.inactive))
+	order := make([]int, 0, len(d.pivots))
+	for j := range d.pivots {
+		order = append(order, j)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(order)))
+	for _, j := range order {
+		row := d.pivots[j]
+		value := row.value
+		for k, a := range row.coefficients {
+			if k != j {
+				d.field.subScaled(value, z[k], a)
+			}
+		}
+		z[j] = value
+	}
+
+	for pos := range d.recovered {
+		if j := d.inactiveIndex[pos]; j >= 0 {
+			d.recovered[pos] = z[j]
+			continue
+		}
+		for j, b := range d.recoveredInactive[pos] {
+			d.field.subScaled(d.recovered[pos], z[j], d.field.neg(b))
+		}
+		d.recoveredInactive[pos] = nil
+	}
+
+	d.complete = true
+	d.Stats.SymbolsNeeded = d.Stats.SymbolsReceived
+	d.symbols = nil
+	d.pivots = nil
+}
+
+// Done reports whether every source symbol has been recovered
+func (d *PeelingDecoder[S, V]) Done() bool {
+	return d.complete
+}
+
+// Result returns the recovered source symbols once decoding is complete
+func (d *PeelingDecoder[S, V]) Result() ([]V, bool) {
+	if !d.complete {
+		return nil, false
+	}
+	return d.recovered, true
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math/big"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cloudflare/circl/group"
+)
+
+// ------------------------
+// Parallel Commitment Engine
+// ------------------------
+
+// CommitmentEngine computes and checks Pedersen commitments on a pool of
+// worker goroutines. Scalar multiplications dominate and are independent,
+// so they spread over cores with no shared state.
+type CommitmentEngine struct {
+	Params  *PedersenParams
+	Workers int // Worker goroutines, 0 means one per core
+}
+
+func NewCommitmentEngine(params *PedersenParams, workers int) *CommitmentEngine {
+	return &CommitmentEngine{Params: params, Workers: workers}
+}
+
+func (e *CommitmentEngine) workers() int {
+	if e.Workers <= 0 {
+		return runtime.NumCPU()
+	}
+	return e.Workers
+}
+
+// parallelFor calls f(i) for every i in [0, n). Workers claim indices in
+// chunks from a shared counter, so uneven work per index still balances.
+func (e *CommitmentEngine) parallelFor(n int, f func(i int)) {
+	workers := e.workers()
+	if workers > n {
+		workers = n
+	}
+	if workers <= 1 {
+		for i := 0; i < n; i++ {
+			f(i)
+		}
+		return
+	}
+	chunk := n / (workers * 8)
+	if chunk < 1 {
+		chunk = 1
+	}
+	var next int64
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				start := int(atomic.AddInt64(&next, int64(chunk))) - chunk
+				if start >= n {
+					return
+				}
+				end := start + chunk
+				if end > n {
+					end = n
+				}
+				for i := start; i < end; i++ {
+					f(i)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// Commit computes the commitment of every message symbol
+func (e *CommitmentEngine) Commit(message, randomness []*big.Int) []group.Element {
+	commitments := make([]group.Element, len(message))
+	e.parallelFor(len(message), func(i int) {
+		commitments[i] = PedersenCommit(e.Params, message[i], randomness[i])
+	})
+	return commitments
+}
+
+// CheckEncoded checks every coded symbol against the combined commitments of
+// its positions, with the randomness summed from the encoder's randomness.
+// It returns the indices of the symbols that fail.
+func (e *CommitmentEngine) CheckEncoded(commitments []group.Element, randomness []*big.Int, encodedSymbols []EncodedSymbol) []int {
+	failed := make([]bool, len(encodedSymbols))
+	e.parallelFor(len(encodedSymbols), func(i int) {
+		es := encodedSymbols[i]
+		rSum := big.NewInt(0)
+		for _, pos := range es.Positions {
+			rSum.Add(rSum, randomness[pos])
+		}
+		combined := CombineCommitments(e.Params, commitments, es.Positions)
+		failed[i] = !combined.IsEqual(PedersenCommit(e.Params, es.Value, rSum))
+	})
+	var bad []int
+	for i, f := range failed {
+		if f {
+			bad = append(bad, i)
+		}
+	}
+	return bad
+}
+
+// Verify checks coded symbols one by one on all workers and returns the
+// value of every good symbol, nil for bad ones
+func (e *CommitmentEngine) Verify(verifier *SymbolVerifier, symbols []*CodedSymbol) []*big.Int {
+	values := make([]*big.Int, len(symbols))
+	e.parallelFor(len(symbols), func(i int) {
+		values[i], _ = verifier.Verify(symbols[i])
+	})
+	return values
+}
+
+// VerifyBatch splits the symbols into one batch per worker and verifies the
+// batches concurrently with VerifyBatch
+func (e *CommitmentEngine) VerifyBatch(verifier *SymbolVerifier, symbols []*CodedSymbol) []*big.Int {
+	workers := e.workers()
+	size := (len(symbols) + workers - 1) / workers
+	values := make([]*big.Int, len(symbols))
+	e.parallelFor(workers, func(w int) {
+		start, end := w*size, (w+1)*size
+		if end > len(symbols) {
+			end = len(symbols)
+		}
+		if start >= end {
+			return
+		}
+		batchValues, _ := verifier.VerifyBatch(symbols[start:end])
+		copy(values[start:end], batchValues)
+	})
+	return values
+}
+
+// coreCounts lists 1, 2, 4, ... up to the number of cores, and the number
+// of cores itself
+func coreCounts() []int {
+	var counts []int
+	for w := 1; w < runtime.NumCPU(); w *= 2 {
+		counts = append(counts, w)
+	}
+	return append(counts, runtime.NumCPU())
+}
+
+// runParallel times source commitments, coded-symbol checks and batched
+// verification for each worker count and prints the speedup over one worker
+func runParallel(params *PedersenParams, numTxs int, soliton RobustSolitonParams, overhead float64, mode CommitmentRandomness, batchSize int, workerCounts []int) {
+	if len(workerCounts) == 0 {
+		workerCounts = coreCounts()
+	}
+	data, err := SerializedBlock(numTxs)
+	if err != nil {
+		panic(err)
+	}
+	message := BytesToSymbols(data, SymbolSize(params.Order))
+	K := len(message)
+	randomness := make([]*big.Int, K)
+	for i := range randomness {
+		randomness[i] = randInt(params.Order)
+	}
+	soliton.K = K
+	encodedSymbols := Encode(message, int(float64(K)*(1+overhead)), params.Order, RobustSolitonDistribution(soliton))
+
+	block := NewSourceBlock(NewCommitmentEngine(params, 0), 0, data, soliton, false, mode)
+	symbols := make([]*CodedSymbol, len(encodedSymbols))
+	for i := range symbols {
+		symbols[i] = block.CodedSymbol(params)
+	}
+
+	fmt.Printf("# group=%s randomness=%s K=%d coded=%d batch=%d cores=%d\n",
+		GroupName(params.Group), mode, K, len(encodedSymbols), batchSize, runtime.NumCPU())
+	fmt.Println("workers,commit_ms,commit_speedup,check_ms,check_speedup,batch_verify_ms,batch_verify_speedup")
+	var base [3]time.Duration
+	for _, workers := range workerCounts {
+		engine := NewCommitmentEngine(params, workers)
+		var times [3]time.Duration
+
+		startTime := time.Now()
+		commitments := engine.Commit(message, randomness)
+		times[0] = time.Since(startTime)
+
+		startTime = time.Now()
+		if bad := engine.CheckEncoded(commitments, randomness, encodedSymbols); len(bad) > 0 {
+			log.Fatalf("%d coded symbols failed the commitment check", len(bad))
+		}
+		times[1] = time.Since(startTime)
+
+		verifier, err := NewSymbolVerifier(engine, block.Header, nil)
+		if err != nil {
+			log.Fatal(err)
+		}
+		startTime = time.Now()
+		for start := 0; start < len(symbols); start += batchSize {
+			end := min(start+batchSize, len(symbols))
+			for _, value := range engine.VerifyBatch(verifier, symbols[start:end]) {
+				if value == nil {
+					log.Fatalf("Honest coded symbol rejected")
+				}
+			}
+		}
+		times[2] = time.Since(startTime)
+
+		if base[0] == 0 {
+			base = times
+		}
+		fmt.Printf("%d,%.3f,%.2f,%.3f,%.2f,%.3f,%.2f\n", workers,
+			times[0].Seconds()*1000, base[0].Seconds()/times[0].Seconds(),
+			times[1].Seconds()*1000, base[1].Seconds()/times[1].Seconds(),
+			times[2].Seconds()*1000, base[2].Seconds()/times[2].Seconds())
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+// ------------------------
+// GF(2^8) Arithmetic
+// ------------------------
+
+// gfPoly is the reduction polynomial x^8 + x^4 + x^3 + x^2 + 1 (0x11d)
+const gfPoly = 0x11d
+
+var (
+	gfExp [512]byte
+	gfLog [256]byte
+)
+
+func init() {
+	x := 1
+	for i := 0; i < 255; i++ {
+		gfExp[i] = byte(x)
+		gfLog[x] = byte(i)
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= gfPoly
+		}
+	}
+	// Duplicate the table so gfMul can skip the mod 255
+	for i := 255; i < 512; i++ {
+		gfExp[i] = gfExp[i-255]
+	}
+}
+
+// Multiply two field elements
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+int(gfLog[b])]
+}
+
+// Multiplicative inverse of a non-zero field element
+func gfInv(a byte) byte {
+	if a == 0 {
+		panic("gf256: inverse of zero")
+	}
+	return gfExp[255-int(gfLog[a])]
+}
+
+// gfMulAdd sets dst = dst + c*src (addition is XOR in characteristic 2)
+func gfMulAdd(dst, src []byte, c byte) {
+	switch c {
+	case 0:
+		return
+	case 1:
+		for i := range dst {
+			dst[i] ^= src[i]
+		}
+		return
+	}
+	logC := int(gfLog[c])
+	for i, s := range src {
+		if s != 0 {
+			dst[i] ^= gfExp[logC+int(gfLog[s])]
+		}
+	}
+}
+
+// gfScale sets v = c*v in place
+func gfScale(v []byte, c byte) {
+	if c == 1 {
+		return
+	}
+	for i, s := range v {
+		v[i] = gfMul(s, c)
+	}
+}
@@ -1,13 +1,17 @@
 package main
 
 import (
+	"bytes"
 	crand "crypto/rand"
 	"encoding/json"
+	"flag"
 	"fmt"
+	"log"
 	"math"
 	"math/big"
 	mrand "math/rand"
 	"os"
+	"sort"
 	"time"
 )
 
@@ -63,20 +67,27 @@ func RobustSolitonDistribution(params RobustSolitonParams) []float64 {
 	return robust
 }
 
-// Select a degree d according to the robust soliton distribution
-func SampleDegree(robust []float64) int {
+// DegreeSampler draws degrees from a precomputed cumulative distribution, so
+// sampling costs O(log K) instead of rebuilding the table for every symbol
+type DegreeSampler struct {
+	cumulative []float64
+}
+
+func NewDegreeSampler(robust []float64) *DegreeSampler {
 	cumulative := make([]float64, len(robust))
-	cumulative[0] = 0.0
 	for i := 1; i < len(robust); i++ {
 		cumulative[i] = cumulative[i-1] + robust[i]
 	}
-	r := randFloat()
-	for i := 1; i < len(cumulative); i++ {
-		if r < cumulative[i] {
-			return i
-		}
+	return &DegreeSampler{cumulative: cumulative}
+}
+
+func (s *DegreeSampler) Sample() int {
+	r := mrand.Float64()
+	d := sort.Search(len(s.cumulative), func(i int) bool { return s.cumulative[i] > r })
+	if d >= len(s.cumulative) {
+		return len(s.cumulative) - 1
 	}
-	return len(robust) - 1
+	return d
 }
 
 // ------------------------
@@ -92,10 +103,11 @@ type EncodedSymbol struct {
 // Encoding function over Zp
 func Encode(message []*big.Int, numEncodedSymbols int, p *big.Int, robust []float64) []EncodedSymbol {
 	K := len(message)
+	sampler := NewDegreeSampler(robust)
 	encodedSymbols := make([]EncodedSymbol, numEncodedSymbols)
 	for i := 0; i < numEncodedSymbols; i++ {
-		d := SampleDegree(robust)
-		positions := randPerm(K, d)
+		d := sampler.Sample()
+		positions := samplePositions(K, d)
 		symbol := big.NewInt(0)
 		for _, pos := range positions {
 			symbol.Add(symbol, message[pos])
@@ -109,198 +121,46 @@ func Encode(message []*big.Int, numEncodedSymbols int, p *big.Int, robust []floa
 	return encodedSymbols
 }
 
-// Peeling decoding over Zp
+// Peeling decoding over Zp. The input symbols are left untouched.
+// Falls back to inactivation decoding when peeling stalls.
 func Decode(encodedSymbols []EncodedSymbol, K int, p *big.Int) ([]*big.Int, bool) {
-	// Initialize the set of unrecovered positions
-	unrecovered := make(map[int]bool)
-	for i := 0; i < K; i++ {
-		unrecovered[i] = true
-	}
-
-	// Make a copy of encodedSymbols to modify during decoding
-	esCopy := make([]EncodedSymbol, len(encodedSymbols))
-	copy(esCopy, encodedSymbols)
-
-	// Initialize queue with encoded symbols of degree one
-	queue := []int{}
-	for i, es := range esCopy {
-		if len(es.Positions) == 1 {
-			queue = append(queue, i)
-		}
-	}
-
-	recovered := make([]*big.Int, K)
-
-	for len(queue) > 0 {
-		esIndex := queue[0]
-		queue = queue[1:]
-		es := esCopy[esIndex]
-
-		// Check if Positions is not empty
-		if len(es.Positions) == 0 {
-			continue
-		}
-
-		pos := es.Positions[0]
-
-		if !unrecovered[pos] {
-			continue
-		}
-
-		value := es.Value
-		recovered[pos] = new(big.Int).Set(value)
-		unrecovered[pos] = false
-
-		// Update other encoded symbols
-		for i, otherEs := range esCopy {
-			if i == esIndex {
-				continue
-			}
-			if contains(otherEs.Positions, pos) {
-				// Subtract the recovered value
-				otherEs.Value.Sub(otherEs.Value, value)
-				otherEs.Value.Mod(otherEs.Value, p)
-				// Remove the position from Positions
-				esCopy[i].Positions = removePosition(otherEs.Positions, pos)
-				if len(esCopy[i].Positions) == 1 {
-					queue = append(queue, i)
-				}
-			}
-		}
-	}
-
-	success := true
-	for _, unrecovered := range unrecovered {
-		if unrecovered {
-			success = false
-			break
-		}
-	}
-
-	if success {
-		return recovered, true
-	} else {
-		return nil, false
-	}
+	recovered, _, success := DecodeWithStats(encodedSymbols, K, p, DecodeOptions{Inactivation: true})
+	return recovered, success
 }
 
-// Helper functions
-func contains(positions []int, pos int) bool {
-	for _, p := range positions {
-		if p == pos {
-			return true
-		}
-	}
-	return false
+// DecodeOptions tunes the Zp decoder
+type DecodeOptions struct {
+	Inactivation bool     // Fall back to inactivation decoding when peeling stalls
+	Precode      *Precode // Symbols were encoded over the precode's intermediate symbols
 }
 
-func removePosition(positions []int, pos int) []int {
-	index := -1
-	for i, p := range positions {
-		if p == pos {
-			index = i
-			break
-		}
-	}
-	if index != -1 {
-		positions = append(positions[:index], positions[index+1:]...)
+// NewZpDecoder returns an incremental decoder for K message symbols over Zp
+func NewZpDecoder(K int, p *big.Int, opts DecodeOptions) *PeelingDecoder[*big.Int, *big.Int] {
+	field := zpField{p: p}
+	var decoder *PeelingDecoder[*big.Int, *big.Int]
+	if opts.Precode != nil {
+		decoder = NewPrecodedDecoder[*big.Int, *big.Int](field, opts.Precode, func() *big.Int { return big.NewInt(0) })
+	} else {
+		decoder = NewPeelingDecoder[*big.Int, *big.Int](field, K)
 	}
-	return positions
-}
-
-// ------------------------
-// Homomorphic Commitments
-// ------------------------
-
-type PedersenParams struct {
-	P *big.Int // Large prime number
-	Q *big.Int // Order of the group
-	G *big.Int // Generator g
-	H *big.Int // Generator h
+	decoder.Inactivation = opts.Inactivation
+	return decoder
 }
 
-// Generate Pedersen Parameters
-func GeneratePedersenParams(bitSize int) (*PedersenParams, error) {
-	var P, Q *big.Int
-	var err error
-
-	for {
-		// Generate a prime Q of bitSize - 1 bits
-		Q, err = crand.Prime(crand.Reader, bitSize-1)
-		if err != nil {
-			return nil, err
-		}
-
-		// Compute P = 2Q + 1
-		P = new(big.Int).Mul(Q, big.NewInt(2))
-		P = P.Add(P, big.NewInt(1))
-
-		// Check if P is prime
-		if P.ProbablyPrime(20) {
-			break // P is a safe prime
-		}
-		// Else, continue the loop to try again
-	}
-	fmt.Println("P:", P.String())
-	fmt.Println("Q:", Q.String())
-	// Choose generators G and H
-	G, err := findGenerator(P, Q)
-	if err != nil {
-		return nil, err
-	}
-	H, err := findGenerator(P, Q)
-	if err != nil {
-		return nil, err
-	}
-	if G.Cmp(H) == 0 {
-		// Ensure G and H are different
-		H, err = findGenerator(P, Q)
-		if err != nil {
-			return nil, err
+// DecodeWithStats decodes like Decode and also reports how many symbols
+// were needed to recover the message and how many were inactivated
+func DecodeWithStats(encodedSymbols []EncodedSymbol, K int, p *big.Int, opts DecodeOptions) ([]*big.Int, DecodeStats, bool) {
+	decoder := NewZpDecoder(K, p, opts)
+	for _, es := range encodedSymbols {
+		if decoder.AddSymbol(es.Positions, nil, es.Value) {
+			break
 		}
 	}
-
-	return &PedersenParams{P: P, Q: Q, G: G, H: H}, nil
-}
-
-// findGenerator finds a generator of the subgroup of order Q in Z_P^*
-func findGenerator(P, Q *big.Int) (*big.Int, error) {
-	one := big.NewInt(1)
-	PMinusOne := new(big.Int).Sub(P, one)
-	exponent := new(big.Int).Div(PMinusOne, Q)
-
-	for {
-		h, err := crand.Int(crand.Reader, new(big.Int).Sub(P, big.NewInt(2)))
-		if err != nil {
-			return nil, err
-		}
-		h.Add(h, big.NewInt(2)) // Ensure 2 <= h < P
-
-		g := new(big.Int).Exp(h, exponent, P)
-
-		if g.Cmp(one) == 0 || g.Cmp(PMinusOne) == 0 {
-			continue // Try another h
-		}
-
-		// Ensure g has order Q
-		gToQ := new(big.Int).Exp(g, Q, P)
-		if gToQ.Cmp(one) != 0 {
-			continue // Try another h
-		}
-
-		return g, nil
+	recovered, success := decoder.Result()
+	if !success {
+		return nil, decoder.Stats, false
 	}
-}
-
-// Compute Pedersen Commitment
-func PedersenCommit(params *PedersenParams, m, r *big.Int) *big.Int {
-	mModQ := new(big.Int).Mod(m, params.Q)
-	rModQ := new(big.Int).Mod(r, params.Q)
-	gm := new(big.Int).Exp(params.G, mModQ, params.P)
-	hr := new(big.Int).Exp(params.H, rModQ, params.P)
-	commitment := new(big.Int).Mul(gm, hr)
-	commitment.Mod(commitment, params.P)
-	return commitment
+	return recovered[:K], decoder.Stats, true
 }
 
 // ------------------------
@@ -315,19 +175,30 @@ func randInt(max *big.Int) *big.Int {
 	return n
 }
 
-func randFloat() float64 {
-	n, err := crand.Int(crand.Reader, new(big.Int).Lsh(big.NewInt(1), 53))
-	if err != nil {
-		panic(err)
-	}
-	return float64(n.Int64()) / (1 << 53)
-}
-
 func randPerm(n, k int) []int {
 	perm := mrand.Perm(n)
 	return perm[:k]
 }
 
+// samplePositions picks k distinct positions out of n. Low degrees use
+// rejection sampling so the cost does not grow with n.
+func samplePositions(n, k int) []int {
+	if 4*k > n {
+		return randPerm(n, k)
+	}
+	seen := make(map[int]struct{}, k)
+	positions := make([]int, 0, k)
+	for len(positions) < k {
+		pos := mrand.Intn(n)
+		if _, ok := seen[pos]; ok {
+			continue
+		}
+		seen[pos] = struct{}{}
+		positions = append(positions, pos)
+	}
+	return positions
+}
+
 // ------------------------
 // Main Function
 // ------------------------
@@ -364,21 +235,196 @@ func readMessageFromJSON(filename string) ([]*big.Int, error) {
 }
 
 func main() {
+	field := flag.String("field", "zp", "Symbol arithmetic: zp (big.Int mod p), xor or gf256")
+	input := flag.String("input", "", "Input file; JSON bigint array for zp, any file (e.g. a serialized Block) otherwise")
+	numTxs := flag.Int("txs", 10000, "Transactions in the generated block when no input file is given (byte codec and sync)")
+	blockSize := flag.Int("blocksize", 1024, "Source block size in bytes (byte codec)")
+	overhead := flag.Float64("overhead", 0.2, "Coded symbols sent as a fraction above K")
+	c := flag.Float64("c", 0.1, "Robust soliton c parameter")
+	delta := flag.Float64("delta", 0.5, "Robust soliton delta parameter")
+	mode := flag.String("mode", "demo", "demo: encode/decode one input, overhead: symbols needed per K, sweep: robust soliton parameter sweep, sync: sync a block from peers over TCP, tamper: check that tampered coded symbols are rejected, batch: per-symbol vs batched verification, parallel: commitment speedup against worker count")
+	ks := flag.String("ks", "1000,5000,10000,50000", "Comma separated values of K for the overhead and sweep modes")
+	trials := flag.Int("trials", 10, "Trials per parameter point for the overhead and sweep modes, symbols per tampering in tamper mode")
+	cs := flag.String("cs", "0.01,0.03,0.1,0.3", "Comma separated values of c for the sweep mode")
+	deltas := flag.String("deltas", "0.01,0.1,0.5", "Comma separated values of delta for the sweep mode")
+	overheads := flag.String("overheads", "0,0.05,0.1,0.2,0.5", "Comma separated reception overheads for the sweep mode")
+	inactivation := flag.Bool("inactivation", true, "Fall back to inactivation decoding when peeling stalls")
+	precode := flag.Bool("precode", false, "Put a Raptor-style LDPC precode in front of LT (overhead mode runs both side by side, sweep mode rejects it)")
+	groupName := flag.String("group", "ristretto255", "Pedersen commitment group: ristretto255, p256 or p384 (zp)")
+	seed := flag.String("seed", DefaultPedersenSeed, "Public seed the Pedersen generator H is derived from (zp)")
+	peers := flag.Int("peers", 10, "Peers holding the block in sync mode")
+	byzantine := flag.Int("byzantine", 3, "Peers sending corrupted symbols in sync mode")
+	port := flag.Int("port", 9000, "First TCP port used in sync mode")
+	randomnessName := flag.String("randomness", "derived", "Commitment randomness in sync, batch and parallel modes: sent by peers, derived from the block hash, or none")
+	batchSize := flag.Int("batch", 1, "Coded symbols verified together in sync mode, 1 checks each symbol on arrival")
+	batchSizes := flag.String("batches", "1,16,64,256,1024", "Comma separated batch sizes for the batch mode")
+	workers := flag.Int("workers", 0, "Commitment worker goroutines, 0 means one per core")
+	workerCounts := flag.String("workerlist", "", "Comma separated worker counts for the parallel mode, default 1, 2, 4, ... up to the core count")
+	flag.Parse()
+
 	mrand.Seed(time.Now().UnixNano())
 
-	// Generate Pedersen Parameters
-	fmt.Println("Generating Pedersen parameters...")
-	pedersenParams, err := GeneratePedersenParams(256)
+	codec := &ByteCodec{BlockSize: *blockSize, Field: FieldXOR, Inactivation: *inactivation, Precode: *precode}
+	if *field == "gf256" {
+		codec.Field = FieldGF256
+	}
+
+	params := RobustSolitonParams{c: *c, delta: *delta}
+
+	switch *mode {
+	case "overhead":
+		Ks, err := parseIntList(*ks)
+		if err != nil {
+			log.Fatalf("Invalid -ks: %v", err)
+		}
+		runOverhead(codec, Ks, params, *trials)
+		return
+	case "sweep":
+		// Behind the precode the LT stage uses the Raptor distribution,
+		// which has no c or delta to sweep
+		if *precode {
+			log.Fatal("The sweep mode covers plain LT only, use -mode overhead to compare the precode")
+		}
+		Ks, err := parseIntList(*ks)
+		if err != nil {
+			log.Fatalf("Invalid -ks: %v", err)
+		}
+		sweep := SweepConfig{Ks: Ks, Trials: *trials}
+		if sweep.Cs, err = parseFloatList(*cs); err != nil {
+			log.Fatalf("Invalid -cs: %v", err)
+		}
+		if sweep.Deltas, err = parseFloatList(*deltas); err != nil {
+			log.Fatalf("Invalid -deltas: %v", err)
+		}
+		if sweep.Overheads, err = parseFloatList(*overheads); err != nil {
+			log.Fatalf("Invalid -overheads: %v", err)
+		}
+		runSweep(codec, sweep)
+		return
+	}
+
+	g, err := ParseGroup(*groupName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	pedersenParams, err := GeneratePedersenParams(g, *seed)
+	if err != nil {
+		log.Fatal(err)
+	}
+	engine := NewCommitmentEngine(pedersenParams, *workers)
+	switch *mode {
+	case "sync":
+		randomness, err := ParseRandomness(*randomnessName)
+		if err != nil {
+			log.Fatal(err)
+		}
+		runSync(pedersenParams, *input, *numTxs, params, SyncConfig{
+			Peers:        *peers,
+			Byzantine:    *byzantine,
+			Port:         *port,
+			Precode:      *precode,
+			Inactivation: *inactivation,
+			Randomness:   randomness,
+			BatchSize:    *batchSize,
+			Workers:      *workers,
+		})
+		return
+	case "batch":
+		randomness, err := ParseRandomness(*randomnessName)
+		if err != nil {
+			log.Fatal(err)
+		}
+		sizes, err := parseIntList(*batchSizes)
+		if err != nil {
+			log.Fatalf("Invalid -batches: %v", err)
+		}
+		runBatch(engine, *numTxs, params, *precode, randomness, sizes)
+		return
+	case "parallel":
+		randomness, err := ParseRandomness(*randomnessName)
+		if err != nil {
+			log.Fatal(err)
+		}
+		var counts []int
+		if *workerCounts != "" {
+			if counts, err = parseIntList(*workerCounts); err != nil {
+				log.Fatalf("Invalid -workerlist: %v", err)
+			}
+		}
+		runParallel(pedersenParams, *numTxs, params, *overhead, randomness, max(*batchSize, 1), counts)
+		return
+	case "tamper":
+		runTamper(engine, *numTxs, params, *precode, *trials)
+		return
+	}
+
+	switch *field {
+	case "zp":
+		if *input == "" {
+			*input = "message.json"
+		}
+		runZp(*input, engine, params, *overhead, DecodeOptions{Inactivation: *inactivation}, *precode)
+	case "xor", "gf256":
+		runBytes(codec, *input, *numTxs, params, *overhead)
+	default:
+		log.Fatalf("Unknown field %q, expected zp, xor or gf256", *field)
+	}
+}
+
+// runBytes encodes and decodes a file or generated block with the byte codec
+func runBytes(codec *ByteCodec, input string, numTxs int, params RobustSolitonParams, overhead float64) {
+	var data []byte
+	var err error
+	if input != "" {
+		data, err = readMessageFromFile(input)
+	} else {
+		data, err = SerializedBlock(numTxs)
+	}
 	if err != nil {
 		panic(err)
 	}
-	fmt.Println("Pedersen parameters generated.")
+
+	blocks := codec.Split(data)
+	K := len(blocks)
+	fmt.Printf("Input size: %d bytes, K = %d blocks of %d bytes (%s, precode %v)\n", len(data), K, codec.BlockSize, codec.Field, codec.Precode)
+
+	robust := codec.DegreeDistribution(K, params)
+
+	// Encoding
+	numEncodedSymbols := int(float64(K) * (1 + overhead))
+	startTime := time.Now()
+	encodedSymbols := codec.Encode(blocks, numEncodedSymbols, robust)
+	fmt.Printf("Time taken For Encoding %d symbols: %f milliseconds\n", numEncodedSymbols, time.Since(startTime).Seconds()*1000)
+
+	// Decoding
+	startTime = time.Now()
+	recoveredBlocks, stats, success := codec.Decode(encodedSymbols, K)
+	if !success {
+		fmt.Println("Decoding failed. Not enough symbols or insufficient degrees.")
+		return
+	}
+	fmt.Printf("Time taken For Decoding: %f milliseconds\n", time.Since(startTime).Seconds()*1000)
+	fmt.Printf("Symbols needed: %d (overhead %.2f%%), inactivated: %d\n", stats.SymbolsNeeded, stats.Overhead()*100, stats.Inactivated)
+
+	if bytes.Equal(JoinBlocks(recoveredBlocks, len(data)), data) {
+		fmt.Println("Recovered data matches the input.")
+	} else {
+		fmt.Println("Recovered data does not match the input.")
+	}
+}
+
+// runZp runs the homomorphic commitment experiment over Zp, where the message
+// symbols are big integers read from a JSON file
+func runZp(input string, engine *CommitmentEngine, params RobustSolitonParams, overhead float64, opts DecodeOptions, precode bool) {
+	pedersenParams := engine.Params
+	fmt.Printf("Pedersen commitments over %s, H derived from seed %q (%d byte commitments)\n",
+		GroupName(pedersenParams.Group), pedersenParams.Seed, CommitmentSize(pedersenParams))
 
 	// Parameters
-	p := pedersenParams.Q // Set p = Q
+	p := pedersenParams.Order // Message symbols live in Z_order
 
 	// Read the message symbols from JSON
-	message, err := readMessageFromJSON("message.json")
+	message, err := readMessageFromJSON(input)
 	if err != nil {
 		panic(err)
 	}
@@ -395,15 +441,13 @@ func main() {
 	// }
 
 	// Compute commitments over the data chunks directly (without hashing)
-	dataCommitments := make([]*big.Int, K)
+	startTime := time.Now()
 	dataRandomness := make([]*big.Int, K)
 	for i := 0; i < K; i++ {
-		mInt := message[i]
-		r := randInt(p)
-		commitment := PedersenCommit(pedersenParams, mInt, r)
-		dataCommitments[i] = commitment
-		dataRandomness[i] = r
+		dataRandomness[i] = randInt(p)
 	}
+	dataCommitments := engine.Commit(message, dataRandomness)
+	fmt.Printf("Time taken For Committing Source Symbols: %f milliseconds\n", time.Since(startTime).Seconds()*1000)
 
 	// Print data commitments
 	// fmt.Println("Data Commitments:")
@@ -412,16 +456,29 @@ func main() {
 	// }
 
 	// Robust Soliton Distribution
-	params := RobustSolitonParams{
-		K:     K,
-		c:     0.1,
-		delta: 0.5,
-	}
+	params.K = K
 	robust := RobustSolitonDistribution(params)
 
+	// Optional precode: parity symbols are sums of source symbols, so their
+	// commitments and randomness follow homomorphically
+	intermediate := message
+	if precode {
+		opts.Precode = NewPrecode(K)
+		intermediate = opts.Precode.ExtendZp(message, p)
+		for _, check := range opts.Precode.Checks {
+			r := big.NewInt(0)
+			for _, pos := range check {
+				r.Add(r, dataRandomness[pos])
+			}
+			dataCommitments = append(dataCommitments, CombineCommitments(pedersenParams, dataCommitments, check))
+			dataRandomness = append(dataRandomness, r.Mod(r, p))
+		}
+		robust = RaptorDegreeDistribution(opts.Precode.L())
+	}
+
 	// Encoding
-	numEncodedSymbols := 1200 // Number of encoded symbols
-	encodedSymbols := Encode(message, numEncodedSymbols, p, robust)
+	numEncodedSymbols := int(float64(K) * (1 + overhead)) // Number of encoded symbols
+	encodedSymbols := Encode(intermediate, numEncodedSymbols, p, robust)
 	// fmt.Println("Encoded Symbols:")
 	// for _, es := range encodedSymbols {
 	// 	sort.Ints(es.Positions)
@@ -429,38 +486,15 @@ func main() {
 	// }
 
 	// Compute commitments over the coded chunks using homomorphic property
-	startTime := time.Now()
-	for idx, es := range encodedSymbols {
-		// Compute combined commitment
-		codedCommitment := big.NewInt(1)
-		for _, pos := range es.Positions {
-			codedCommitment.Mul(codedCommitment, dataCommitments[pos])
-			codedCommitment.Mod(codedCommitment, pedersenParams.P)
-		}
-
-		// Sum the randomness values
-		rSum := big.NewInt(0)
-		for _, pos := range es.Positions {
-			rSum.Add(rSum, dataRandomness[pos])
-		}
-		rSum.Mod(rSum, pedersenParams.Q)
-
-		// Compute commitment over the coded chunk directly
-		codedChunkValue := es.Value
-		computedCodedCommitment := PedersenCommit(pedersenParams, codedChunkValue, rSum)
-
-		// Verify that the commitments match
-		if codedCommitment.Cmp(computedCodedCommitment) == 0 {
-			// fmt.Printf("Encoded Symbol %d: Commitment verification successful.\n", idx)
-		} else {
-			fmt.Printf("Encoded Symbol %d: Commitment verification failed.\n", idx)
-		}
+	startTime = time.Now()
+	for _, idx := range engine.CheckEncoded(dataCommitments, dataRandomness, encodedSymbols) {
+		fmt.Printf("Encoded Symbol %d: Commitment verification failed.\n", idx)
 	}
 	fmt.Printf("Time taken For Computing Commitments: %f milliseconds\n", time.Since(startTime).Seconds()*1000)
 
 	// Decoding
 	startTime = time.Now()
-	recoveredMessage, success := Decode(encodedSymbols, K, p)
+	recoveredMessage, stats, success := DecodeWithStats(encodedSymbols, K, p, opts)
 	if success {
 		// fmt.Println("Recovered message:")
 		// for i := 0; i < K; i++ {
@@ -468,6 +502,7 @@ func main() {
 		// }
 		// Print the duration in milliseconds
 		fmt.Printf("Time taken For Decoding: %f milliseconds\n", time.Since(startTime).Seconds()*1000)
+		fmt.Printf("Symbols needed: %d of %d (overhead %.2f%%), inactivated: %d\n", stats.SymbolsNeeded, numEncodedSymbols, stats.Overhead()*100, stats.Inactivated)
 	} else {
 		fmt.Println("Decoding failed. Not enough symbols or insufficient degrees.")
 	}
@@ -476,10 +511,8 @@ func main() {
 	startTime = time.Now()
 	if success {
 		allVerified := true
-		for i := 0; i < K; i++ {
-			mInt := recoveredMessage[i]
-			commitment := PedersenCommit(pedersenParams, mInt, dataRandomness[i])
-			if commitment.Cmp(dataCommitments[i]) != 0 {
+		for i, commitment := range engine.Commit(recoveredMessage, dataRandomness[:K]) {
+			if !commitment.IsEqual(dataCommitments[i]) {
 				fmt.Printf("Commitment verification failed for message symbol %d.\n", i)
 				allVerified = false
 			}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ------------------------
+// Reception Overhead
+// ------------------------
+
+// TrialResult is the outcome of one overhead trial
+type TrialResult struct {
+	Stats      DecodeStats
+	DecodeTime time.Duration
+	AvgDegree  float64 // Mean degree of the symbols sent
+	MaxDegree  int     // Highest degree among the symbols sent
+}
+
+// OverheadTrial streams freshly encoded symbols into an incremental decoder
+// until every block is recovered or maxSymbols have been sent
+func OverheadTrial(codec *ByteCodec, blocks [][]byte, dist []float64, maxSymbols int) TrialResult {
+	intermediate := codec.Intermediate(blocks)
+	sampler := NewDegreeSampler(dist)
+	decoder := codec.NewDecoder(len(blocks))
+	var result TrialResult
+	edges := 0
+	for i := 0; i < maxSymbols; i++ {
+		symbol := codec.EncodeSymbol(intermediate, sampler)
+		edges += len(symbol.Positions)
+		if len(symbol.Positions) > result.MaxDegree {
+			result.MaxDegree = len(symbol.Positions)
+		}
+		start := time.Now()
+		done := decoder.AddSymbol(symbol.Positions, symbol.Coefficients, symbol.Data)
+		result.DecodeTime += time.Since(start)
+		if done {
+			break
+		}
+	}
+	result.Stats = decoder.Stats
+	result.AvgDegree = float64(edges) / float64(decoder.Stats.SymbolsReceived)
+	return result
+}
+
+// runOverhead prints the symbols needed per trial as CSV for plotting. With
+// the precode enabled every trial is run for plain LT and Raptor alike.
+func runOverhead(codec *ByteCodec, Ks []int, params RobustSolitonParams, trials int) {
+	schemes := []struct {
+		name  string
+		codec *ByteCodec
+	}{{"lt", codec}}
+	if codec.Precode {
+		plain := *codec
+		plain.Precode = false
+		schemes = []struct {
+			name  string
+			codec *ByteCodec
+		}{{"lt", &plain}, {"raptor", codec}}
+	}
+
+	fmt.Println("scheme,K,trial,symbols_needed,overhead,inactivated,avg_degree,max_degree,decode_ms")
+	for _, K := range Ks {
+		data := make([]byte, K*codec.BlockSize)
+		if _, err := rand.Read(data); err != nil {
+			panic(err)
+		}
+		blocks := codec.Split(data)
+		for _, scheme := range schemes {
+			dist := scheme.codec.DegreeDistribution(K, params)
+			for t := 0; t < trials; t++ {
+				r := OverheadTrial(scheme.codec, blocks, dist, 3*K)
+				needed, overhead := "", ""
+				if r.Stats.SymbolsNeeded > 0 {
+					needed = strconv.Itoa(r.Stats.SymbolsNeeded)
+					overhead = strconv.FormatFloat(r.Stats.Overhead(), 'f', 6, 64)
+				}
+				fmt.Printf("%s,%d,%d,%s,%s,%d,%f,%d,%f\n", scheme.name, K, t, needed, overhead,
+					r.Stats.Inactivated, r.AvgDegree, r.MaxDegree, r.DecodeTime.Seconds()*1000)
+			}
+		}
+	}
+}
+
+func parseIntList(s string) ([]int, error) {
+	var values []int
+	for _, field := range strings.Split(s, ",") {
+		v, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+func parseFloatList(s string) ([]float64, error) {
+	var values []float64
+	for _, field := range strings.Split(s, ",") {
+		v, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
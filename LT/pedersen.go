@@ -0,0 +1,176 @@
+package main
+
+import (
+	"crypto/elliptic"
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+
+	"github.com/cloudflare/circl/group"
+)
+
+// ------------------------
+// Homomorphic Commitments
+// ------------------------
+
+// pedersenDST is the domain separation tag for deriving H. Anyone can rerun
+// the derivation from the public seed, which is the nothing-up-my-sleeve
+// argument: H comes out of a hash-to-curve, so nobody knows log_G(H).
+const pedersenDST = "thesis_simulation-LT-Pedersen-H-v1"
+
+// DefaultPedersenSeed is the public seed H is derived from
+const DefaultPedersenSeed = "LT coded symbol commitments"
+
+// pedersenRandomnessDST separates derived blinding factors from other hashes
+const pedersenRandomnessDST = "thesis_simulation-LT-Pedersen-r-v1"
+
+// CommitmentRandomness says where the blinding factors of the source
+// commitments come from
+type CommitmentRandomness int
+
+const (
+	// RandomnessSent keeps the blinding factors private to the nodes holding
+	// the block, peers send the summed randomness with every coded symbol
+	RandomnessSent CommitmentRandomness = iota
+	// RandomnessDerived derives r_i from the block hash and i, so receivers
+	// recompute the randomness from the header and peers send none
+	RandomnessDerived
+	// RandomnessNone commits to m*G only. The block is public anyway, so
+	// hiding buys nothing once the block is synced.
+	RandomnessNone
+)
+
+func (r CommitmentRandomness) String() string {
+	switch r {
+	case RandomnessSent:
+		return "sent"
+	case RandomnessDerived:
+		return "derived"
+	case RandomnessNone:
+		return "none"
+	}
+	return "unknown"
+}
+
+// ParseRandomness maps a name to a CommitmentRandomness
+func ParseRandomness(name string) (CommitmentRandomness, error) {
+	for _, r := range []CommitmentRandomness{RandomnessSent, RandomnessDerived, RandomnessNone} {
+		if r.String() == name {
+			return r, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown randomness %q, expected sent, derived or none", name)
+}
+
+// DeriveRandomness is the blinding factor of symbol i of a block. SHA-512
+// gives 256 bits of slack over the supported orders, so the modular bias is
+// negligible.
+func DeriveRandomness(params *PedersenParams, blockSeed []byte, i int) *big.Int {
+	var index [8]byte
+	binary.BigEndian.PutUint64(index[:], uint64(i))
+	h := sha512.New()
+	h.Write([]byte(pedersenRandomnessDST))
+	h.Write(blockSeed)
+	h.Write(index[:])
+	r := new(big.Int).SetBytes(h.Sum(nil))
+	return r.Mod(r, params.Order)
+}
+
+// PedersenParams describes Pedersen commitments m*G + r*H over a prime-order
+// elliptic curve group. G is the standard generator of the group.
+type PedersenParams struct {
+	Group group.Group
+	G     group.Element // Standard generator of the group
+	H     group.Element // HashToElement(Seed, pedersenDST)
+	Seed  string        // Public seed H is derived from
+	Order *big.Int      // Group order, message symbols live in Z_Order
+}
+
+// ParseGroup maps a group name to a prime-order curve group
+func ParseGroup(name string) (group.Group, error) {
+	switch name {
+	case "ristretto255":
+		return group.Ristretto255, nil
+	case "p256":
+		return group.P256, nil
+	case "p384":
+		return group.P384, nil
+	}
+	return nil, fmt.Errorf("unknown group %q, expected ristretto255, p256 or p384", name)
+}
+
+// GroupName is the inverse of ParseGroup
+func GroupName(g group.Group) string {
+	switch g {
+	case group.Ristretto255:
+		return "ristretto255"
+	case group.P256:
+		return "p256"
+	case group.P384:
+		return "p384"
+	}
+	return "unknown"
+}
+
+// groupOrder returns the prime order of the supported groups
+func groupOrder(g group.Group) (*big.Int, error) {
+	switch g {
+	case group.Ristretto255:
+		// 2^252 + 27742317777372353535851937790883648493
+		l, _ := new(big.Int).SetString("27742317777372353535851937790883648493", 10)
+		return l.Add(l, new(big.Int).Lsh(big.NewInt(1), 252)), nil
+	case group.P256:
+		return elliptic.P256().Params().N, nil
+	case group.P384:
+		return elliptic.P384().Params().N, nil
+	}
+	return nil, fmt.Errorf("unsupported group %v", g)
+}
+
+// GeneratePedersenParams derives the commitment parameters from a public
+// seed. The same group and seed always give the same H.
+func GeneratePedersenParams(g group.Group, seed string) (*PedersenParams, error) {
+	order, err := groupOrder(g)
+	if err != nil {
+		return nil, err
+	}
+	H := g.HashToElement([]byte(seed), []byte(pedersenDST))
+	if H.IsIdentity() {
+		return nil, fmt.Errorf("seed %q hashes to the identity", seed)
+	}
+	return &PedersenParams{
+		Group: g,
+		G:     g.Generator(),
+		H:     H,
+		Seed:  seed,
+		Order: order,
+	}, nil
+}
+
+// Scalar reduces x modulo the group order
+func (params *PedersenParams) Scalar(x *big.Int) group.Scalar {
+	return params.Group.NewScalar().SetBigInt(new(big.Int).Mod(x, params.Order))
+}
+
+// Compute Pedersen Commitment m*G + r*H
+func PedersenCommit(params *PedersenParams, m, r *big.Int) group.Element {
+	gm := params.Group.NewElement().MulGen(params.Scalar(m))
+	hr := params.Group.NewElement().Mul(params.H, params.Scalar(r))
+	return gm.Add(gm, hr)
+}
+
+// CombineCommitments sums the commitments at the given positions, which by
+// the homomorphic property commits to the sum of the committed values
+func CombineCommitments(params *PedersenParams, commitments []group.Element, positions []int) group.Element {
+	combined := params.Group.Identity()
+	for _, pos := range positions {
+		combined.Add(combined, commitments[pos])
+	}
+	return combined
+}
+
+// CommitmentSize is the size of a compressed commitment in bytes
+func CommitmentSize(params *PedersenParams) int {
+	return int(params.Group.Params().CompressedElementLength)
+}
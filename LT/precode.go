@@ -0,0 +1,126 @@
+package main
+
+import (
+	"math"
+	"math/big"
+	mrand "math/rand"
+)
+
+// ------------------------
+// Raptor-style Precode
+// ------------------------
+
+// Precode is a systematic LDPC outer code. The K source symbols are extended
+// with S parity symbols, each the sum of the source symbols it checks, and
+// the LT stage then encodes all L = K + S intermediate symbols. The check
+// graph is derived from K alone so sender and receiver agree on it without
+// exchanging anything.
+type Precode struct {
+	K      int
+	S      int
+	Checks [][]int // Source positions summed into each parity symbol
+}
+
+// Each source symbol takes part in this many parity checks
+const precodeChecksPerSymbol = 3
+
+// NewPrecode builds the check graph for K source symbols. S follows the
+// RFC 5053 sizing of about 1% of K plus X where X(X-1) >= 2K.
+func NewPrecode(K int) *Precode {
+	X := int(math.Ceil((1 + math.Sqrt(1+8*float64(K))) / 2))
+	S := int(math.Ceil(0.01*float64(K))) + X
+	if S < precodeChecksPerSymbol {
+		S = precodeChecksPerSymbol
+	}
+
+	rng := mrand.New(mrand.NewSource(int64(K)))
+	checks := make([][]int, S)
+	// Partial Fisher-Yates: each source symbol only shuffles the few checks
+	// it picks to the front, the order left behind is as good as any
+	perm := make([]int, S)
+	for j := range perm {
+		perm[j] = j
+	}
+	for i := 0; i < K; i++ {
+		for k := 0; k < precodeChecksPerSymbol; k++ {
+			j := k + rng.Intn(S-k)
+			perm[k], perm[j] = perm[j], perm[k]
+			checks[perm[k]] = append(checks[perm[k]], i)
+		}
+	}
+	return &Precode{K: K, S: S, Checks: checks}
+}
+
+// L is the number of intermediate symbols the LT stage encodes
+func (pc *Precode) L() int {
+	return pc.K + pc.S
+}
+
+// ExtendBlocks appends the parity blocks to the source blocks
+func (pc *Precode) ExtendBlocks(blocks [][]byte) [][]byte {
+	intermediate := append([][]byte(nil), blocks...)
+	for _, check := range pc.Checks {
+		parity := make([]byte, len(blocks[0]))
+		for _, pos := range check {
+			gfMulAdd(parity, blocks[pos], 1)
+		}
+		intermediate = append(intermediate, parity)
+	}
+	return intermediate
+}
+
+// ExtendZp appends the parity symbols to a message over Zp
+func (pc *Precode) ExtendZp(message []*big.Int, p *big.Int) []*big.Int {
+	intermediate := append([]*big.Int(nil), message...)
+	for _, check := range pc.Checks {
+		parity := big.NewInt(0)
+		for _, pos := range check {
+			parity.Add(parity, message[pos])
+		}
+		intermediate = append(intermediate, parity.Mod(parity, p))
+	}
+	return intermediate
+}
+
+// NewPrecodedDecoder returns a decoder over the L intermediate symbols that
+// already knows every parity check, written as sum(sources) - parity = 0.
+// Its stats count overhead against the K source symbols.
+func NewPrecodedDecoder[S, V any](field symbolField[S, V], pc *Precode, zero func() V) *PeelingDecoder[S, V] {
+	decoder := NewPeelingDecoder[S, V](field, pc.L())
+	decoder.Stats.K = pc.K
+	for j, check := range pc.Checks {
+		positions := append(append([]int(nil), check...), pc.K+j)
+		coefficients := make([]S, len(positions))
+		for i := range check {
+			coefficients[i] = field.one()
+		}
+		coefficients[len(check)] = field.neg(field.one())
+		decoder.AddConstraint(positions, coefficients, zero())
+	}
+	return decoder
+}
+
+// RaptorDegreeDistribution is the RFC 5053 LT degree distribution. With a
+// precode in front the LT stage only needs to recover most intermediate
+// symbols, so it can use a small maximum degree instead of the robust
+// soliton tail. Degrees above L are folded into L.
+func RaptorDegreeDistribution(L int) []float64 {
+	thresholds := []struct {
+		degree int
+		upper  float64
+	}{
+		{1, 10241}, {2, 491582}, {3, 712794}, {4, 831695},
+		{10, 948446}, {11, 1032189}, {40, 1048576},
+	}
+	dist := make([]float64, L+1)
+	prev := 0.0
+	for _, t := range thresholds {
+		d := t.degree
+		if d > L {
+			d = L
+		}
+		dist[d] += (t.upper - prev) / 1048576
+		prev = t.upper
+	}
+	return dist
+}
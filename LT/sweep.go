@@ -0,0 +1,101 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+)
+
+// ------------------------
+// Robust Soliton Parameter Sweep
+// ------------------------
+
+// SweepConfig lists the parameter grid of a sweep
+type SweepConfig struct {
+	Ks        []int
+	Cs        []float64
+	Deltas    []float64
+	Overheads []float64 // Reception overheads at which success is reported
+	Trials    int       // Encode/decode trials per (K, c, delta)
+}
+
+// SweepPoint aggregates the trials of one (K, c, delta) combination
+type SweepPoint struct {
+	K          int
+	C          float64
+	Delta      float64
+	AvgDegree  float64 // Mean of the degree distribution, drives commitment cost
+	Completed  int     // Trials that decoded within 3K symbols
+	NeededSum  int     // Symbols needed summed over completed trials
+	Successes  []int   // Trials decoded within each overhead of the config
+	TrialCount int
+}
+
+// MeanDegree is the expected degree of a coded symbol
+func MeanDegree(dist []float64) float64 {
+	mean := 0.0
+	for d, p := range dist {
+		mean += float64(d) * p
+	}
+	return mean
+}
+
+// SweepTrials runs cfg.Trials streaming trials for one parameter point. A
+// single trial tells at which symbol count decoding finished, so every
+// overhead is checked against the same run. The codec must not use the
+// precode, whose LT stage ignores the robust soliton parameters.
+func SweepTrials(codec *ByteCodec, blocks [][]byte, params RobustSolitonParams, cfg SweepConfig) SweepPoint {
+	K := len(blocks)
+	params.K = K
+	dist := RobustSolitonDistribution(params)
+	point := SweepPoint{
+		K:          K,
+		C:          params.c,
+		Delta:      params.delta,
+		AvgDegree:  MeanDegree(dist),
+		Successes:  make([]int, len(cfg.Overheads)),
+		TrialCount: cfg.Trials,
+	}
+	for t := 0; t < cfg.Trials; t++ {
+		r := OverheadTrial(codec, blocks, dist, 3*K)
+		needed := r.Stats.SymbolsNeeded
+		if needed == 0 {
+			continue
+		}
+		point.Completed++
+		point.NeededSum += needed
+		for i, overhead := range cfg.Overheads {
+			if needed <= int(float64(K)*(1+overhead)) {
+				point.Successes[i]++
+			}
+		}
+	}
+	return point
+}
+
+// runSweep prints one CSV row per (K, c, delta, overhead)
+func runSweep(codec *ByteCodec, cfg SweepConfig) {
+	fmt.Printf("# field=%s inactivation=%v trials=%d\n", codec.Field, codec.Inactivation, cfg.Trials)
+	fmt.Println("K,c,delta,avg_degree,mean_symbols_needed,mean_overhead,overhead,success_prob")
+	for _, K := range cfg.Ks {
+		data := make([]byte, K*codec.BlockSize)
+		if _, err := rand.Read(data); err != nil {
+			panic(err)
+		}
+		blocks := codec.Split(data)
+		for _, c := range cfg.Cs {
+			for _, delta := range cfg.Deltas {
+				point := SweepTrials(codec, blocks, RobustSolitonParams{c: c, delta: delta}, cfg)
+				meanNeeded, meanOverhead := "", ""
+				if point.Completed > 0 {
+					mean := float64(point.NeededSum) / float64(point.Completed)
+					meanNeeded = fmt.Sprintf("%.1f", mean)
+					meanOverhead = fmt.Sprintf("%.4f", (mean-float64(K))/float64(K))
+				}
+				for i, overhead := range cfg.Overheads {
+					fmt.Printf("%d,%g,%g,%.3f,%s,%s,%g,%.4f\n", K, c, delta, point.AvgDegree,
+						meanNeeded, meanOverhead, overhead, float64(point.Successes[i])/float64(point.TrialCount))
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,642 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/cloudflare/circl/group"
+)
+
+// ------------------------
+// Homomorphic Sync Protocol
+// ------------------------
+
+// A lagging node syncs a block from its peers without per-symbol proofs. The
+// block header carries a Pedersen commitment to every source symbol. Peers
+// stream LT coded symbols, each with the summed randomness of the source
+// symbols it combines, and the lagging node checks
+//
+//	sum(C_pos) == value*G + rSum*H
+//
+// before a symbol reaches the peeling decoder. A corrupt symbol fails the
+// check and gets its sender blacklisted. With derived or no randomness the
+// lagging node knows every r_i from the header, folds r_i*H into the
+// commitments once, and only value*G is left to check per symbol.
+
+// SyncHeader is the part of a block every node agrees on through consensus
+type SyncHeader struct {
+	BlockID     int
+	Length      int      // Block size in bytes
+	BlockHash   []byte   // sha256 of the block bytes
+	Group       string   // Commitment group
+	Seed        string   // Seed H was derived from
+	Precode     bool     // Symbols are coded over the precode's intermediate symbols
+	Randomness  string   // Where the commitment randomness comes from
+	Commitments [][]byte // Compressed commitment of each source symbol
+}
+
+// Digest identifies the header, the lagging node learns it from the chain
+func (h *SyncHeader) Digest() []byte {
+	headerBytes, err := json.Marshal(h)
+	if err != nil {
+		panic(err)
+	}
+	digest := sha256.Sum256(headerBytes)
+	return digest[:]
+}
+
+// CodedSymbol is what a peer sends per LT coded symbol
+type CodedSymbol struct {
+	Positions  []int
+	Value      []byte // Coded value in Z_order, big endian
+	Randomness []byte `json:",omitempty"` // Summed randomness, only with RandomnessSent
+}
+
+// SyncMessage frames everything sent over a sync connection
+type SyncMessage struct {
+	Type    string // "header", "symbols" or "symbol"
+	NodeID  int
+	BlockID int
+	Header  *SyncHeader  `json:",omitempty"`
+	Symbol  *CodedSymbol `json:",omitempty"`
+}
+
+// SymbolSize is the number of block bytes packed into one Z_order symbol
+func SymbolSize(order *big.Int) int {
+	return (order.BitLen() - 1) / 8
+}
+
+// BytesToSymbols packs data into symbols of size bytes, zero padding the tail
+func BytesToSymbols(data []byte, size int) []*big.Int {
+	K := (len(data) + size - 1) / size
+	symbols := make([]*big.Int, K)
+	for i := range symbols {
+		end := (i + 1) * size
+		if end > len(data) {
+			end = len(data)
+		}
+		chunk := make([]byte, size)
+		copy(chunk, data[i*size:end])
+		symbols[i] = new(big.Int).SetBytes(chunk)
+	}
+	return symbols
+}
+
+// SymbolsToBytes reverses BytesToSymbols and drops the padding
+func SymbolsToBytes(symbols []*big.Int, size, length int) []byte {
+	data := make([]byte, 0, len(symbols)*size)
+	for _, s := range symbols {
+		data = append(data, s.FillBytes(make([]byte, size))...)
+	}
+	return data[:length]
+}
+
+// scalarBytes encodes x in the fixed width of the group order
+func scalarBytes(params *PedersenParams, x *big.Int) []byte {
+	return x.FillBytes(make([]byte, (params.Order.BitLen()+7)/8))
+}
+
+// SourceBlock is a block as held by a node that already has it, together
+// with the commitment openings needed to serve coded symbols
+type SourceBlock struct {
+	Header       *SyncHeader
+	Intermediate []*big.Int // Source symbols, then precode parity
+	Randomness   []*big.Int // Commitment randomness of every intermediate symbol
+	mode         CommitmentRandomness
+	sampler      *DegreeSampler
+}
+
+// NewSourceBlock splits data into symbols and commits to each of them
+func NewSourceBlock(engine *CommitmentEngine, blockID int, data []byte, soliton RobustSolitonParams, precode bool, mode CommitmentRandomness) *SourceBlock {
+	params := engine.Params
+	blockHash := sha256.Sum256(data)
+	message := BytesToSymbols(data, SymbolSize(params.Order))
+	K := len(message)
+	header := &SyncHeader{
+		BlockID:     blockID,
+		Length:      len(data),
+		BlockHash:   blockHash[:],
+		Group:       GroupName(params.Group),
+		Seed:        params.Seed,
+		Precode:     precode,
+		Randomness:  mode.String(),
+		Commitments: make([][]byte, K),
+	}
+	randomness := make([]*big.Int, K)
+	for i := range message {
+		switch mode {
+		case RandomnessSent:
+			randomness[i] = randInt(params.Order)
+		case RandomnessDerived:
+			randomness[i] = DeriveRandomness(params, header.BlockHash, i)
+		default:
+			randomness[i] = big.NewInt(0)
+		}
+	}
+	commitments := engine.Commit(message, randomness)
+	engine.parallelFor(K, func(i int) {
+		commitment, err := commitments[i].MarshalBinaryCompress()
+		if err != nil {
+			panic(err)
+		}
+		header.Commitments[i] = commitment
+	})
+
+	soliton.K = K
+	dist := RobustSolitonDistribution(soliton)
+	intermediate := message
+	if precode {
+		pc := NewPrecode(K)
+		intermediate = pc.ExtendZp(message, params.Order)
+		for _, check := range pc.Checks {
+			r := big.NewInt(0)
+			for _, pos := range check {
+				r.Add(r, randomness[pos])
+			}
+			randomness = append(randomness, r.Mod(r, params.Order))
+		}
+		dist = RaptorDegreeDistribution(pc.L())
+	}
+	return &SourceBlock{
+		Header:       header,
+		Intermediate: intermediate,
+		Randomness:   randomness,
+		mode:         mode,
+		sampler:      NewDegreeSampler(dist),
+	}
+}
+
+// CodedSymbol draws a fresh LT coded symbol with its summed randomness
+func (b *SourceBlock) CodedSymbol(params *PedersenParams) *CodedSymbol {
+	positions := samplePositions(len(b.Intermediate), b.sampler.Sample())
+	value := big.NewInt(0)
+	r := big.NewInt(0)
+	for _, pos := range positions {
+		value.Add(value, b.Intermediate[pos])
+		r.Add(r, b.Randomness[pos])
+	}
+	value.Mod(value, params.Order)
+	symbol := &CodedSymbol{
+		Positions: positions,
+		Value:     scalarBytes(params, value),
+	}
+	if b.mode == RandomnessSent {
+		symbol.Randomness = scalarBytes(params, r.Mod(r, params.Order))
+	}
+	return symbol
+}
+
+// SymbolVerifier checks coded symbols against the commitments of a header
+type SymbolVerifier struct {
+	params      *PedersenParams
+	mode        CommitmentRandomness
+	commitments []group.Element // Source commitments, then precode parity, minus r_i*H unless sent
+}
+
+// NewSymbolVerifier decodes the header commitments. With a precode the
+// parity commitments follow homomorphically from the source commitments.
+// Derived randomness is removed from the commitments up front.
+func NewSymbolVerifier(engine *CommitmentEngine, header *SyncHeader, pc *Precode) (*SymbolVerifier, error) {
+	params := engine.Params
+	if header.Group != GroupName(params.Group) || header.Seed != params.Seed {
+		return nil, fmt.Errorf("header commits over %s with seed %q, expected %s with seed %q",
+			header.Group, header.Seed, GroupName(params.Group), params.Seed)
+	}
+	mode, err := ParseRandomness(header.Randomness)
+	if err != nil {
+		return nil, err
+	}
+	commitments := make([]group.Element, len(header.Commitments))
+	errs := make([]error, len(header.Commitments))
+	engine.parallelFor(len(header.Commitments), func(i int) {
+		commitments[i] = params.Group.NewElement()
+		if errs[i] = commitments[i].UnmarshalBinary(header.Commitments[i]); errs[i] != nil {
+			return
+		}
+		if mode == RandomnessDerived {
+			rH := params.Group.NewElement().Mul(params.H, params.Scalar(DeriveRandomness(params, header.BlockHash, i)))
+			commitments[i].Add(commitments[i], rH.Neg(rH))
+		}
+	})
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("commitment %d: %v", i, err)
+		}
+	}
+	if pc != nil {
+		for _, check := range pc.Checks {
+			commitments = append(commitments, CombineCommitments(params, commitments, check))
+		}
+	}
+	return &SymbolVerifier{params: params, mode: mode, commitments: commitments}, nil
+}
+
+// parse checks the shape of a coded symbol and returns its value and
+// randomness, the randomness is zero unless it is sent
+func (v *SymbolVerifier) parse(cs *CodedSymbol) (*big.Int, *big.Int, bool) {
+	if len(cs.Positions) == 0 {
+		return nil, nil, false
+	}
+	seen := make(map[int]bool, len(cs.Positions))
+	for _, pos := range cs.Positions {
+		if pos < 0 || pos >= len(v.commitments) || seen[pos] {
+			return nil, nil, false
+		}
+		seen[pos] = true
+	}
+	value := new(big.Int).SetBytes(cs.Value)
+	if value.Cmp(v.params.Order) >= 0 {
+		return nil, nil, false
+	}
+	if v.mode != RandomnessSent {
+		// The receiver knows the randomness, so a peer has nothing to send
+		if len(cs.Randomness) != 0 {
+			return nil, nil, false
+		}
+		return value, big.NewInt(0), true
+	}
+	r := new(big.Int).SetBytes(cs.Randomness)
+	if len(cs.Randomness) == 0 || r.Cmp(v.params.Order) >= 0 {
+		return nil, nil, false
+	}
+	return value, r, true
+}
+
+// Verify checks a coded symbol and returns its value when it is consistent
+// with the commitments
+func (v *SymbolVerifier) Verify(cs *CodedSymbol) (*big.Int, bool) {
+	value, r, ok := v.parse(cs)
+	if !ok {
+		return nil, false
+	}
+	combined := CombineCommitments(v.params, v.commitments, cs.Positions)
+	if !combined.IsEqual(v.commit(value, r)) {
+		return nil, false
+	}
+	return value, true
+}
+
+// commit is the commitment a symbol's value and randomness should match.
+// Unless randomness is sent it is already folded into the commitments.
+func (v *SymbolVerifier) commit(value, r *big.Int) group.Element {
+	if v.mode == RandomnessSent {
+		return PedersenCommit(v.params, value, r)
+	}
+	return v.params.Group.NewElement().MulGen(v.params.Scalar(value))
+}
+
+// ------------------------
+// Sync Nodes
+// ------------------------
+
+// SyncConfig describes a sync run
+type SyncConfig struct {
+	Peers        int  // Peers holding the block
+	Byzantine    int  // Peers that corrupt the symbols they send
+	Port         int  // First TCP port, the lagging node is not listening
+	Precode      bool // Precode the block before LT
+	Inactivation bool // Fall back to inactivation decoding when peeling stalls
+	Randomness   CommitmentRandomness
+	BatchSize    int // Symbols the lagging node verifies together
+	Workers      int // Verification workers of the lagging node, 0 means one per core
+}
+
+// SyncNode is a peer serving a block, or the lagging node when Block is nil
+type SyncNode struct {
+	ID          int
+	Address     string
+	Listener    net.Listener
+	Params      *PedersenParams
+	Engine      *CommitmentEngine // Commitment work of the lagging node
+	Block       *SourceBlock
+	IsByzantine bool
+	Peers       map[int]string
+	BlackList   map[int]bool
+	BatchSize   int // Symbols verified together, 1 checks each symbol on arrival
+	Metrics     *HomomorphicSyncMetrics
+}
+
+// HomomorphicSyncMetrics tracks one sync of the lagging node
+type HomomorphicSyncMetrics struct {
+	NodeID           int
+	K                int
+	StartTime        time.Time
+	EndTime          time.Time
+	HeaderTime       time.Duration // Fetching and checking the header
+	SetupTime        time.Duration // Decoding the header commitments
+	SymbolsReceived  int           // Coded symbols read from peers
+	SymbolsAccepted  int           // Symbols that passed the commitment check
+	SymbolsRejected  int           // Symbols that failed the commitment check
+	SymbolsNeeded    int           // Accepted symbols the decoder consumed
+	Inactivated      int
+	BytesRead        int64         // Bytes read from all sync connections
+	VerificationTime time.Duration // Time spent checking symbols
+	DecodeTime       time.Duration // Time spent in the decoder
+	TotalDuration    time.Duration
+}
+
+// countingConn counts the bytes read from a connection
+type countingConn struct {
+	net.Conn
+	read *int64
+	mu   *sync.Mutex
+}
+
+func (c countingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.mu.Lock()
+	*c.read += int64(n)
+	c.mu.Unlock()
+	return n, err
+}
+
+// InitializeSyncNetwork starts cfg.Peers peers holding block and returns the
+// lagging node with all of them as peers
+func InitializeSyncNetwork(params *PedersenParams, block *SourceBlock, cfg SyncConfig) *SyncNode {
+	lagging := &SyncNode{
+		ID:        0,
+		Params:    params,
+		Peers:     make(map[int]string),
+		BlackList: make(map[int]bool),
+		Engine:    NewCommitmentEngine(params, cfg.Workers),
+		BatchSize: max(cfg.BatchSize, 1),
+	}
+	for i := 1; i <= cfg.Peers; i++ {
+		address := fmt.Sprintf("localhost:%d", cfg.Port+i)
+		listener, err := net.Listen("tcp", address)
+		if err != nil {
+			log.Fatalf("Error starting listener: %v", err)
+		}
+		node := &SyncNode{
+			ID:          i,
+			Address:     address,
+			Listener:    listener,
+			Params:      params,
+			Block:       block,
+			IsByzantine: i <= cfg.Byzantine,
+		}
+		if node.IsByzantine {
+			fmt.Println("Node", i, "is Byzantine")
+		}
+		lagging.Peers[i] = address
+		go node.Serve()
+	}
+	return lagging
+}
+
+// Serve answers header and symbol requests until the listener is closed
+func (n *SyncNode) Serve() {
+	for {
+		conn, err := n.Listener.Accept()
+		if err != nil {
+			return
+		}
+		go n.handleSyncConnection(conn)
+	}
+}
+
+func (n *SyncNode) handleSyncConnection(conn net.Conn) {
+	defer conn.Close()
+	var request SyncMessage
+	if err := json.NewDecoder(conn).Decode(&request); err != nil {
+		log.Printf("Node %d error reading request: %v", n.ID, err)
+		return
+	}
+	encoder := json.NewEncoder(conn)
+	switch request.Type {
+	case "header":
+		encoder.Encode(&SyncMessage{Type: "header", NodeID: n.ID, BlockID: request.BlockID, Header: n.Block.Header})
+	case "symbols":
+		// LT is rateless, so keep streaming fresh symbols until the
+		// lagging node has enough and hangs up
+		for {
+			symbol := n.Block.CodedSymbol(n.Params)
+			if n.IsByzantine {
+				n.corrupt(symbol)
+			}
+			if err := encoder.Encode(&SyncMessage{Type: "symbol", NodeID: n.ID, BlockID: request.BlockID, Symbol: symbol}); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// corrupt tampers with the value of a coded symbol
+func (n *SyncNode) corrupt(symbol *CodedSymbol) {
+	value := new(big.Int).SetBytes(symbol.Value)
+	value.Add(value, bigOne).Mod(value, n.Params.Order)
+	symbol.Value = scalarBytes(n.Params, value)
+}
+
+// fetchHeader asks the peers in turn for the header with the given digest
+func (n *SyncNode) fetchHeader(blockID int, digest []byte) (*SyncHeader, error) {
+	for peerID := 1; peerID <= len(n.Peers); peerID++ {
+		conn, err := net.Dial("tcp", n.Peers[peerID])
+		if err != nil {
+			log.Printf("Error connecting to peer %d: %v", peerID, err)
+			continue
+		}
+		var response SyncMessage
+		err = json.NewEncoder(conn).Encode(&SyncMessage{Type: "header", NodeID: n.ID, BlockID: blockID})
+		if err == nil {
+			err = json.NewDecoder(conn).Decode(&response)
+		}
+		conn.Close()
+		if err != nil || response.Header == nil || !bytes.Equal(response.Header.Digest(), digest) {
+			fmt.Println("Peer", peerID, "sent an invalid header")
+			n.BlackList[peerID] = true
+			continue
+		}
+		return response.Header, nil
+	}
+	return nil, fmt.Errorf("no peer served header %x", digest)
+}
+
+type symbolArrival struct {
+	peer   int
+	symbol *CodedSymbol
+}
+
+// Sync fetches the header and then pulls coded symbols from every peer in
+// parallel until the block decodes. It returns the block bytes.
+func (n *SyncNode) Sync(blockID int, digest []byte, opts DecodeOptions) ([]byte, error) {
+	n.Metrics = &HomomorphicSyncMetrics{NodeID: n.ID, StartTime: time.Now()}
+
+	header, err := n.fetchHeader(blockID, digest)
+	if err != nil {
+		return nil, err
+	}
+	n.Metrics.HeaderTime = time.Since(n.Metrics.StartTime)
+	K := len(header.Commitments)
+	n.Metrics.K = K
+	if header.Precode {
+		opts.Precode = NewPrecode(K)
+	}
+	startTime := time.Now()
+	verifier, err := NewSymbolVerifier(n.Engine, header, opts.Precode)
+	if err != nil {
+		return nil, err
+	}
+	n.Metrics.SetupTime = time.Since(startTime)
+	decoder := NewZpDecoder(K, n.Params.Order, opts)
+
+	arrivals := make(chan symbolArrival, 64)
+	done := make(chan struct{})
+	conns := make(map[int]net.Conn)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for peerID, address := range n.Peers {
+		if n.BlackList[peerID] {
+			continue
+		}
+		conn, err := net.Dial("tcp", address)
+		if err != nil {
+			log.Printf("Error connecting to peer %d: %v", peerID, err)
+			continue
+		}
+		conns[peerID] = conn
+		wg.Add(1)
+		go func(peerID int, conn net.Conn) {
+			defer wg.Done()
+			if err := json.NewEncoder(conn).Encode(&SyncMessage{Type: "symbols", NodeID: n.ID, BlockID: blockID}); err != nil {
+				return
+			}
+			stream := json.NewDecoder(countingConn{Conn: conn, read: &n.Metrics.BytesRead, mu: &mu})
+			for {
+				var message SyncMessage
+				if err := stream.Decode(&message); err != nil {
+					return
+				}
+				if message.Type != "symbol" || message.Symbol == nil {
+					continue
+				}
+				select {
+				case arrivals <- symbolArrival{peer: peerID, symbol: message.Symbol}:
+				case <-done:
+					return
+				}
+			}
+		}(peerID, conn)
+	}
+	go func() {
+		wg.Wait()
+		close(arrivals)
+	}()
+
+	// process verifies a batch of arrivals and feeds the good symbols to
+	// the decoder, it reports whether the block is complete
+	process := func(batch []symbolArrival) bool {
+		startTime := time.Now()
+		values := make([]*big.Int, len(batch))
+		if len(batch) == 1 {
+			values[0], _ = verifier.Verify(batch[0].symbol)
+		} else {
+			symbols := make([]*CodedSymbol, len(batch))
+			for i, arrival := range batch {
+				symbols[i] = arrival.symbol
+			}
+			values = n.Engine.VerifyBatch(verifier, symbols)
+		}
+		n.Metrics.VerificationTime += time.Since(startTime)
+
+		for i, arrival := range batch {
+			if values[i] == nil {
+				n.Metrics.SymbolsRejected++
+				if !n.BlackList[arrival.peer] {
+					fmt.Println("Symbol from peer", arrival.peer, "failed the commitment check, blacklisting it")
+					n.BlackList[arrival.peer] = true
+					conns[arrival.peer].Close()
+				}
+				continue
+			}
+			n.Metrics.SymbolsAccepted++
+
+			startTime = time.Now()
+			complete := decoder.AddSymbol(arrival.symbol.Positions, nil, values[i])
+			n.Metrics.DecodeTime += time.Since(startTime)
+			if complete {
+				return true
+			}
+		}
+		return false
+	}
+
+	var pending []symbolArrival
+	complete := false
+	for arrival := range arrivals {
+		if n.BlackList[arrival.peer] {
+			continue
+		}
+		n.Metrics.SymbolsReceived++
+		pending = append(pending, arrival)
+		if len(pending) >= n.BatchSize {
+			complete = process(pending)
+			pending = pending[:0]
+			if complete {
+				break
+			}
+		}
+	}
+	if !complete && len(pending) > 0 {
+		process(pending)
+	}
+	close(done)
+	for _, conn := range conns {
+		conn.Close()
+	}
+	wg.Wait()
+
+	recovered, success := decoder.Result()
+	if !success {
+		return nil, fmt.Errorf("ran out of peers after %d accepted symbols", n.Metrics.SymbolsAccepted)
+	}
+	n.Metrics.SymbolsNeeded = decoder.Stats.SymbolsNeeded
+	n.Metrics.Inactivated = decoder.Stats.Inactivated
+	data := SymbolsToBytes(recovered[:K], SymbolSize(n.Params.Order), header.Length)
+	if blockHash := sha256.Sum256(data); !bytes.Equal(blockHash[:], header.BlockHash) {
+		return nil, fmt.Errorf("recovered block does not match the header hash")
+	}
+	n.Metrics.EndTime = time.Now()
+	n.Metrics.TotalDuration = n.Metrics.EndTime.Sub(n.Metrics.StartTime)
+	return data, nil
+}
+
+// runSync syncs one block from cfg.Peers local peers over TCP
+func runSync(params *PedersenParams, input string, numTxs int, soliton RobustSolitonParams, cfg SyncConfig) {
+	var data []byte
+	var err error
+	if input != "" {
+		data, err = readMessageFromFile(input)
+	} else {
+		data, err = SerializedBlock(numTxs)
+	}
+	if err != nil {
+		panic(err)
+	}
+	if cfg.Byzantine >= cfg.Peers {
+		log.Fatalf("Need at least one honest peer, got %d Byzantine of %d", cfg.Byzantine, cfg.Peers)
+	}
+
+	startTime := time.Now()
+	block := NewSourceBlock(NewCommitmentEngine(params, cfg.Workers), 0, data, soliton, cfg.Precode, cfg.Randomness)
+	fmt.Printf("Block of %d bytes in %d symbols of %d bytes, committed over %s with %s randomness in %f milliseconds\n",
+		len(data), len(block.Header.Commitments), SymbolSize(params.Order), GroupName(params.Group), cfg.Randomness, time.Since(startTime).Seconds()*1000)
+
+	lagging := InitializeSyncNetwork(params, block, cfg)
+	recovered, err := lagging.Sync(0, block.Header.Digest(), DecodeOptions{Inactivation: cfg.Inactivation})
+	if err != nil {
+		log.Fatalf("Sync failed: %v", err)
+	}
+	if !bytes.Equal(recovered, data) {
+		log.Fatalf("Recovered block does not match the original")
+	}
+	fmt.Printf("Sync Metrics for Node %d: %+v\n", lagging.ID, *lagging.Metrics)
+	fmt.Println("Blacklisted peers:", lagging.BlackList)
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math/big"
+)
+
+// ------------------------
+// Tamper Checks
+// ------------------------
+
+// A tampering changes a valid coded symbol. It reports false when it does
+// not apply to the randomness mode.
+type tampering struct {
+	name  string
+	apply func(params *PedersenParams, cs *CodedSymbol, L int) bool
+}
+
+// addOne adds one to a big endian scalar modulo the group order
+func addOne(params *PedersenParams, b []byte) []byte {
+	x := new(big.Int).SetBytes(b)
+	return scalarBytes(params, x.Add(x, bigOne).Mod(x, params.Order))
+}
+
+var tamperings = []tampering{
+	{"value", func(params *PedersenParams, cs *CodedSymbol, L int) bool {
+		cs.Value = addOne(params, cs.Value)
+		return true
+	}},
+	{"randomness", func(params *PedersenParams, cs *CodedSymbol, L int) bool {
+		if cs.Randomness == nil {
+			return false
+		}
+		cs.Randomness = addOne(params, cs.Randomness)
+		return true
+	}},
+	{"value_and_randomness", func(params *PedersenParams, cs *CodedSymbol, L int) bool {
+		if cs.Randomness == nil {
+			return false
+		}
+		cs.Value = addOne(params, cs.Value)
+		cs.Randomness = addOne(params, cs.Randomness)
+		return true
+	}},
+	{"extra_randomness", func(params *PedersenParams, cs *CodedSymbol, L int) bool {
+		// Randomness the receiver should derive itself
+		if cs.Randomness != nil {
+			return false
+		}
+		cs.Randomness = scalarBytes(params, bigOne)
+		return true
+	}},
+	{"value_out_of_range", func(params *PedersenParams, cs *CodedSymbol, L int) bool {
+		// Same value modulo the order, but not canonical
+		x := new(big.Int).SetBytes(cs.Value)
+		cs.Value = x.Add(x, params.Order).Bytes()
+		return true
+	}},
+	{"moved_position", func(params *PedersenParams, cs *CodedSymbol, L int) bool {
+		cs.Positions = append([]int(nil), cs.Positions...)
+		cs.Positions[0] = (cs.Positions[0] + 1) % L
+		for _, pos := range cs.Positions[1:] {
+			if pos == cs.Positions[0] {
+				return false
+			}
+		}
+		return true
+	}},
+	{"dropped_position", func(params *PedersenParams, cs *CodedSymbol, L int) bool {
+		if len(cs.Positions) < 2 {
+			return false
+		}
+		cs.Positions = cs.Positions[1:]
+		return true
+	}},
+	{"duplicate_position", func(params *PedersenParams, cs *CodedSymbol, L int) bool {
+		cs.Positions = append(append([]int(nil), cs.Positions...), cs.Positions[0])
+		return true
+	}},
+	{"position_out_of_range", func(params *PedersenParams, cs *CodedSymbol, L int) bool {
+		cs.Positions = append(append([]int(nil), cs.Positions[1:]...), L)
+		return true
+	}},
+}
+
+// runTamper checks that honest coded symbols pass the commitment check and
+// every tampered variant fails it, for each randomness mode. It exits non
+// zero on the first wrong verdict.
+func runTamper(engine *CommitmentEngine, numTxs int, soliton RobustSolitonParams, precode bool, trials int) {
+	params := engine.Params
+	data, err := SerializedBlock(numTxs)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("# group=%s precode=%v block=%d bytes\n", GroupName(params.Group), precode, len(data))
+	fmt.Println("randomness,tampering,symbols,rejected")
+	for _, mode := range []CommitmentRandomness{RandomnessSent, RandomnessDerived, RandomnessNone} {
+		block := NewSourceBlock(engine, 0, data, soliton, precode, mode)
+		var pc *Precode
+		if precode {
+			pc = NewPrecode(len(block.Header.Commitments))
+		}
+		verifier, err := NewSymbolVerifier(engine, block.Header, pc)
+		if err != nil {
+			log.Fatal(err)
+		}
+		L := len(block.Intermediate)
+
+		symbols := make([]*CodedSymbol, trials)
+		for i := range symbols {
+			symbols[i] = block.CodedSymbol(params)
+			if _, ok := verifier.Verify(symbols[i]); !ok {
+				log.Fatalf("Honest symbol %d rejected with %s randomness", i, mode)
+			}
+		}
+		fmt.Printf("%s,honest,%d,0\n", mode, trials)
+
+		for _, t := range tamperings {
+			tried, rejected := 0, 0
+			for _, honest := range symbols {
+				cs := *honest
+				if !t.apply(params, &cs, L) {
+					continue
+				}
+				tried++
+				if _, ok := verifier.Verify(&cs); !ok {
+					rejected++
+				}
+			}
+			if tried == 0 {
+				continue
+			}
+			fmt.Printf("%s,%s,%d,%d\n", mode, t.name, tried, rejected)
+			if rejected != tried {
+				log.Fatalf("%d tampered symbols (%s) accepted with %s randomness", tried-rejected, t.name, mode)
+			}
+		}
+	}
+	fmt.Println("# all tampered symbols rejected")
+}
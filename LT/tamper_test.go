@@ -0,0 +1,105 @@
+package main
+
+import (
+	"math/big"
+	"testing"
+)
+
+// newTestVerifier commits a small block with the given randomness mode and
+// returns it with a verifier for its header
+func newTestVerifier(t *testing.T, mode CommitmentRandomness, precode bool) (*SourceBlock, *SymbolVerifier) {
+	t.Helper()
+	g, err := ParseGroup("ristretto255")
+	if err != nil {
+		t.Fatal(err)
+	}
+	params, err := GeneratePedersenParams(g, DefaultPedersenSeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine := NewCommitmentEngine(params, 1)
+	data, err := SerializedBlock(20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block := NewSourceBlock(engine, 0, data, RobustSolitonParams{c: 0.1, delta: 0.5}, precode, mode)
+	var pc *Precode
+	if precode {
+		pc = NewPrecode(len(block.Header.Commitments))
+	}
+	verifier, err := NewSymbolVerifier(engine, block.Header, pc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return block, verifier
+}
+
+func TestSymbolVerifierRejectsTampering(t *testing.T) {
+	// Tamperings every mode must reject, on top of the mode's own
+	always := []string{"value", "value_out_of_range", "moved_position", "duplicate_position", "position_out_of_range"}
+	modes := map[CommitmentRandomness][]string{
+		RandomnessSent:    {"randomness", "value_and_randomness"},
+		RandomnessDerived: {"extra_randomness"},
+		RandomnessNone:    {"extra_randomness"},
+	}
+	for mode, own := range modes {
+		for _, precode := range []bool{false, true} {
+			block, verifier := newTestVerifier(t, mode, precode)
+			params := verifier.params
+			L := len(block.Intermediate)
+			applied := make(map[string]bool)
+			for i := 0; i < 30; i++ {
+				honest := block.CodedSymbol(params)
+				if _, ok := verifier.Verify(honest); !ok {
+					t.Fatalf("%s randomness, precode %v: honest symbol rejected", mode, precode)
+				}
+				for _, tamper := range tamperings {
+					cs := *honest
+					if !tamper.apply(params, &cs, L) {
+						continue
+					}
+					applied[tamper.name] = true
+					if _, ok := verifier.Verify(&cs); ok {
+						t.Errorf("%s randomness, precode %v: %s symbol accepted", mode, precode, tamper.name)
+					}
+				}
+			}
+			for _, name := range append(always, own...) {
+				if !applied[name] {
+					t.Errorf("%s randomness, precode %v: %s never tried", mode, precode, name)
+				}
+			}
+		}
+	}
+}
+
+func TestSymbolVerifierRejectsMalformedRandomness(t *testing.T) {
+	block, verifier := newTestVerifier(t, RandomnessSent, false)
+	params := verifier.params
+
+	// Randomness must be sent in this mode
+	cs := block.CodedSymbol(params)
+	cs.Randomness = nil
+	if _, ok := verifier.Verify(cs); ok {
+		t.Error("symbol without randomness accepted")
+	}
+
+	// Same randomness modulo the order, but not canonical
+	cs = block.CodedSymbol(params)
+	r := new(big.Int).SetBytes(cs.Randomness)
+	cs.Randomness = r.Add(r, params.Order).Bytes()
+	if _, ok := verifier.Verify(cs); ok {
+		t.Error("symbol with randomness above the order accepted")
+	}
+}
+
+func TestSymbolVerifierRejectsEmptyPositions(t *testing.T) {
+	for _, mode := range []CommitmentRandomness{RandomnessSent, RandomnessDerived, RandomnessNone} {
+		block, verifier := newTestVerifier(t, mode, false)
+		cs := block.CodedSymbol(verifier.params)
+		cs.Positions = nil
+		if _, ok := verifier.Verify(cs); ok {
+			t.Errorf("%s randomness: symbol without positions accepted", mode)
+		}
+	}
+}
@@ -4,14 +4,16 @@ go 1.22.1
 
 require (
 	github.com/cbergoon/merkletree v0.2.0
+	github.com/cloudflare/circl v1.6.1
 	github.com/klauspost/reedsolomon v1.12.1
 	github.com/tendermint/tendermint v0.35.9
 )
 
 require (
-	github.com/cloud9-tools/go-galoisfield v0.0.0-20160311182916-a8cf2bffadf0 // indirect
+	github.com/bwesterb/go-ristretto v1.2.3 // indirect
 	github.com/gogo/protobuf v1.3.2 // indirect
 	github.com/klauspost/cpuid/v2 v2.2.6 // indirect
 	github.com/stretchr/testify v1.8.2 // indirect
-	golang.org/x/sys v0.9.0 // indirect
+	golang.org/x/crypto v0.11.1-0.20230711161743-2e82bdd1719d // indirect
+	golang.org/x/sys v0.10.0 // indirect
 )
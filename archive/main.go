@@ -1,13 +1,13 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"log"
 	"math/big"
 	"math/rand"
 	"sync"
 	"time"
-
-	"github.com/tendermint/tendermint/crypto/merkle"
 )
 
 // Number of Servers + Number of Coded chunks
@@ -18,14 +18,14 @@ var k = 100
 
 type Chunk struct {
 	Data  []byte
-	Proof merkle.Proof
+	Proof []byte // Serialized opening proof of the run's vector commitment
 }
 
 type Server struct {
 	ID         int
 	Chunks     []Chunk
 	Commitment []byte
-	Proofs     []*merkle.Proof
+	Opener     VectorOpener // Opens positions of Commitment on request
 }
 
 type Client struct {
@@ -51,7 +51,7 @@ func (client *Client) RequestChunk(server *Server, chunkIndex int) {
 	chunk := server.RespondToRequest(chunkIndex)
 	SimulateBandwidthLimit(len(chunk.Data))
 
-	if VerifyChunk(client, server.Commitment, chunk, &server.Chunks[chunkIndex].Proof) {
+	if VerifyChunk(client, server.Commitment, chunkIndex, chunk) {
 		if client.VerifiedChunks == nil {
 			client.VerifiedChunks = make(map[int]Chunk)
 		}
@@ -64,7 +64,13 @@ func (client *Client) RequestChunk(server *Server, chunkIndex int) {
 func (server *Server) RespondToRequest(chunkIndex int) Chunk {
 	SimulateNetworkDelay()
 
-	return server.Chunks[chunkIndex]
+	chunk := server.Chunks[chunkIndex]
+	proof, err := server.Opener.Open(chunkIndex)
+	if err != nil {
+		panic(err)
+	}
+	chunk.Proof = proof
+	return chunk
 }
 
 func Servers(data []byte) []Server {
@@ -93,11 +99,7 @@ func SimulateExperiment(filename string) {
 		wg.Add(1)
 		go func(i int) {
 			defer wg.Done()
-			commitment, proofs := CreateVectorCommitment(servers[i].Chunks)
-			servers[i].Commitment = commitment
-			for j := range servers[i].Chunks {
-				servers[i].Chunks[j].Proof = *proofs[j]
-			}
+			servers[i].Commitment, servers[i].Opener = CreateVectorCommitment(servers[i].Chunks)
 		}(i)
 	}
 	wg.Wait()
@@ -129,5 +131,11 @@ func SimulateExperiment(filename string) {
 }
 
 func main() {
+	scheme := flag.String("vc", "merkle", "Vector commitment over the coded chunks: merkle, kzg or pedersen-list")
+	flag.Parse()
+	var err error
+	if vectorCommitment, err = NewVectorCommitment(*scheme, n); err != nil {
+		log.Fatal(err)
+	}
 	SimulateExperiment("eth_transactions.json")
 }
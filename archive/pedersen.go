@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+
+	"github.com/cloudflare/circl/group"
+)
+
+// ###################################
+// Lists of Pedersen commitments over ristretto255
+//
+// Every chunk gets its own commitment C_i = H(chunk_i)*G + r_i*H and the
+// scheme commits to the chunks with the list of them. This is not a vector
+// commitment: it grows with N, 32 bytes per chunk, where Merkle and KZG stay
+// constant, while the proof of a chunk is just its randomness r_i.
+
+const (
+	pedersenListDST  = "thesis_simulation-chunk-Pedersen-v1"
+	pedersenListSeed = "coded chunk commitments"
+)
+
+// PedersenCommitmentList holds the generators, H comes out of a
+// hash-to-curve so nobody knows its discrete log
+type PedersenCommitmentList struct {
+	group group.Group
+	H     group.Element
+}
+
+func NewPedersenCommitmentList() (*PedersenCommitmentList, error) {
+	g := group.Ristretto255
+	H := g.HashToElement([]byte(pedersenListSeed), []byte(pedersenListDST))
+	if H.IsIdentity() {
+		return nil, errors.New("pedersen generator is the identity")
+	}
+	return &PedersenCommitmentList{group: g, H: H}, nil
+}
+
+func (pc *PedersenCommitmentList) Name() string { return "pedersen-list" }
+
+// commit computes H(data)*G + r*H
+func (pc *PedersenCommitmentList) commit(data []byte, r group.Scalar) group.Element {
+	m := pc.group.HashToScalar(data, []byte(pedersenListDST))
+	commitment := pc.group.NewElement().MulGen(m)
+	return commitment.Add(commitment, pc.group.NewElement().Mul(pc.H, r))
+}
+
+// pedersenOpener keeps the randomness of every chunk
+type pedersenOpener []group.Scalar
+
+func (pc *PedersenCommitmentList) Commit(data [][]byte) ([]byte, VectorOpener, error) {
+	var commitment bytes.Buffer
+	randomness := make(pedersenOpener, len(data))
+	for i, chunk := range data {
+		randomness[i] = pc.group.RandomScalar(rand.Reader)
+		c, err := pc.commit(chunk, randomness[i]).MarshalBinaryCompress()
+		if err != nil {
+			return nil, nil, err
+		}
+		commitment.Write(c)
+	}
+	return commitment.Bytes(), randomness, nil
+}
+
+func (o pedersenOpener) Open(index int) ([]byte, error) {
+	if index < 0 || index >= len(o) {
+		return nil, fmt.Errorf("index %d out of range", index)
+	}
+	return o[index].MarshalBinary()
+}
+
+func (pc *PedersenCommitmentList) Verify(commitment []byte, index int, data []byte, proof []byte) error {
+	size := int(pc.group.Params().CompressedElementLength)
+	if index < 0 || (index+1)*size > len(commitment) {
+		return fmt.Errorf("index %d out of range", index)
+	}
+	expected := pc.group.NewElement()
+	if err := expected.UnmarshalBinary(commitment[index*size : (index+1)*size]); err != nil {
+		return err
+	}
+	r := pc.group.NewScalar()
+	if err := r.UnmarshalBinary(proof); err != nil {
+		return err
+	}
+	if !pc.commit(data, r).IsEqual(expected) {
+		return errors.New("pedersen opening does not match the commitment")
+	}
+	return nil
+}
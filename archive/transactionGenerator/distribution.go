@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	mrand "math/rand"
+	"strconv"
+	"strings"
+)
+
+// Distribution draws non-negative integers, such as the number of inputs of
+// a transaction or the size of its payload. It is written as kind:params:
+//
+//	fixed:n           always n
+//	uniform:a:b       a to b, both included
+//	poisson:lambda    Poisson with mean lambda
+//	geometric:p       failures before the first success of probability p
+//	lognormal:mu:sd   exp of a normal with mean mu and deviation sd, rounded
+type Distribution struct {
+	Kind   string
+	Params []float64
+}
+
+// maxSample bounds every draw, so a heavy tail cannot ask for a payload or
+// an input list too large to allocate
+const maxSample = 1 << 20
+
+var distributionParams = map[string]int{
+	"fixed":     1,
+	"uniform":   2,
+	"poisson":   1,
+	"geometric": 1,
+	"lognormal": 2,
+}
+
+func ParseDistribution(spec string) (Distribution, error) {
+	parts := strings.Split(spec, ":")
+	count, ok := distributionParams[parts[0]]
+	if !ok {
+		return Distribution{}, fmt.Errorf("unknown distribution %q", parts[0])
+	}
+	if len(parts)-1 != count {
+		return Distribution{}, fmt.Errorf("distribution %s takes %d parameters", parts[0], count)
+	}
+	d := Distribution{Kind: parts[0]}
+	for _, part := range parts[1:] {
+		param, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return Distribution{}, fmt.Errorf("distribution %s: %v", spec, err)
+		}
+		// NaN passes every comparison below, so it is refused here
+		if math.IsNaN(param) || math.IsInf(param, 0) {
+			return Distribution{}, fmt.Errorf("distribution %s has a parameter that is not finite", spec)
+		}
+		d.Params = append(d.Params, param)
+	}
+	switch {
+	case d.Kind == "fixed" && d.Params[0] < 0,
+		d.Kind == "uniform" && (d.Params[0] < 0 || d.Params[1] < d.Params[0]),
+		d.Kind == "poisson" && d.Params[0] < 0,
+		d.Kind == "geometric" && (d.Params[0] <= 0 || d.Params[0] > 1),
+		d.Kind == "lognormal" && d.Params[1] < 0:
+		return Distribution{}, fmt.Errorf("distribution %s out of range", spec)
+	}
+	if mean := d.Mean(); mean > maxSample {
+		return Distribution{}, fmt.Errorf("distribution %s has mean %g, above the %d cap", spec, mean, maxSample)
+	}
+	// Capping would pile a uniform's top values onto the cap
+	if d.Kind == "uniform" && d.Params[1] > maxSample {
+		return Distribution{}, fmt.Errorf("distribution %s goes above the %d cap", spec, maxSample)
+	}
+	return d, nil
+}
+
+// Mean is the expected value of a draw before it is capped
+func (d Distribution) Mean() float64 {
+	switch d.Kind {
+	case "fixed", "poisson":
+		return d.Params[0]
+	case "uniform":
+		return (d.Params[0] + d.Params[1]) / 2
+	case "geometric":
+		return (1 - d.Params[0]) / d.Params[0]
+	case "lognormal":
+		return math.Exp(d.Params[0] + d.Params[1]*d.Params[1]/2)
+	}
+	panic("unknown distribution " + d.Kind)
+}
+
+func (d Distribution) String() string {
+	parts := []string{d.Kind}
+	for _, param := range d.Params {
+		parts = append(parts, strconv.FormatFloat(param, 'g', -1, 64))
+	}
+	return strings.Join(parts, ":")
+}
+
+// Set and String make a Distribution a flag.Value
+func (d *Distribution) Set(spec string) error {
+	parsed, err := ParseDistribution(spec)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// Sample draws a value, capped at maxSample
+func (d Distribution) Sample(rng *mrand.Rand) int {
+	switch d.Kind {
+	case "fixed":
+		return capSample(d.Params[0])
+	case "uniform":
+		low, high := int(d.Params[0]), int(d.Params[1])
+		return low + rng.Intn(high-low+1)
+	case "poisson":
+		return poisson(rng, d.Params[0])
+	case "geometric":
+		if d.Params[0] == 1 {
+			return 0
+		}
+		return capSample(math.Log(1-rng.Float64()) / math.Log(1-d.Params[0]))
+	case "lognormal":
+		return capSample(math.Round(math.Exp(d.Params[0] + d.Params[1]*rng.NormFloat64())))
+	}
+	panic("unknown distribution " + d.Kind)
+}
+
+// capSample converts a draw to an int before it can overflow one
+func capSample(x float64) int {
+	return int(math.Min(x, maxSample))
+}
+
+// poisson uses Knuth's method for small means and a normal approximation
+// for large ones
+func poisson(rng *mrand.Rand, lambda float64) int {
+	if lambda > 30 {
+		return max(0, int(math.Round(lambda+math.Sqrt(lambda)*rng.NormFloat64())))
+	}
+	limit := math.Exp(-lambda)
+	k := 0
+	for p := rng.Float64(); p > limit; p *= rng.Float64() {
+		k++
+	}
+	return k
+}
@@ -3,12 +3,12 @@ package main
 import (
 	"crypto/rand"
 	"encoding/hex"
-	"encoding/json"
+	"flag"
 	"fmt"
+	"hash/fnv"
 	"math"
 	"math/big"
 	mrand "math/rand"
-	"os"
 
 	"github.com/ethereum/go-ethereum/common"
 )
@@ -19,6 +19,7 @@ type ETHTransaction struct {
 	Value    float64 `json:"value"`
 	GasPrice float64 `json:"gas_price"`
 	Nonce    uint64  `json:"nonce"`
+	Data     string  `json:"data,omitempty"` // Hex payload, like call data
 }
 
 type BTCTransaction struct {
@@ -26,6 +27,7 @@ type BTCTransaction struct {
 	Vin  []Vin   `json:"vin"`
 	Vout []Vout  `json:"vout"`
 	Fee  float64 `json:"fee"`
+	Data string  `json:"data,omitempty"` // Hex payload, like an OP_RETURN output
 }
 
 type Vin struct {
@@ -42,27 +44,40 @@ func randomETHAddress(rng *mrand.Rand) string {
 	return common.HexToAddress(fmt.Sprintf("0x%x", rng.Uint64())).Hex()
 }
 
-func randomBTCAddress() string {
+// TransactionShape sets the distributions the generated transactions are
+// drawn from
+type TransactionShape struct {
+	Inputs  Distribution // Inputs of a BTC transaction, at least one
+	Outputs Distribution // Outputs of a BTC transaction, at least one
+	Payload Distribution // Payload bytes of a transaction
+}
+
+func randomBTCAddress(rng *mrand.Rand) string {
 	address := make([]byte, 20)
-	if _, err := rand.Read(address); err != nil {
-		panic("failed to read random bytes for address: " + err.Error())
-	}
+	rng.Read(address)
 	return fmt.Sprintf("1%s", hex.EncodeToString(address))
 }
 
-func randomETHTransaction(rng *mrand.Rand) ETHTransaction {
+func randomPayload(rng *mrand.Rand, size int) string {
+	payload := make([]byte, size)
+	rng.Read(payload)
+	return hex.EncodeToString(payload)
+}
+
+func randomETHTransaction(rng *mrand.Rand, shape TransactionShape) ETHTransaction {
 	return ETHTransaction{
 		From:     randomETHAddress(rng),
 		To:       randomETHAddress(rng),
 		Value:    rng.Float64() * 100,
 		GasPrice: rng.Float64() * 100,
 		Nonce:    rng.Uint64(),
+		Data:     randomPayload(rng, shape.Payload.Sample(rng)),
 	}
 }
 
-func randomBTCTransaction(rng *mrand.Rand) BTCTransaction {
-	vinCount := rng.Intn(5) + 1
-	voutCount := rng.Intn(5) + 1
+func randomBTCTransaction(rng *mrand.Rand, shape TransactionShape) BTCTransaction {
+	vinCount := max(1, shape.Inputs.Sample(rng))
+	voutCount := max(1, shape.Outputs.Sample(rng))
 	vin := make([]Vin, vinCount)
 	vout := make([]Vout, voutCount)
 	for i := range vin {
@@ -74,7 +89,7 @@ func randomBTCTransaction(rng *mrand.Rand) BTCTransaction {
 	for i := range vout {
 		vout[i] = Vout{
 			Value:        rng.Float64() * 10,
-			ScriptPubKey: randomBTCAddress(),
+			ScriptPubKey: randomBTCAddress(rng),
 		}
 	}
 	return BTCTransaction{
@@ -82,53 +97,85 @@ func randomBTCTransaction(rng *mrand.Rand) BTCTransaction {
 		Vin:  vin,
 		Vout: vout,
 		Fee:  rng.Float64(),
+		Data: randomPayload(rng, shape.Payload.Sample(rng)),
 	}
 }
 
 func main() {
-	seed, err := rand.Int(rand.Reader, big.NewInt(math.MaxInt64))
-	if err != nil {
-		fmt.Println("Error generating random seed:", err)
+	shape := TransactionShape{
+		Inputs:  Distribution{Kind: "uniform", Params: []float64{1, 5}},
+		Outputs: Distribution{Kind: "uniform", Params: []float64{1, 5}},
+		Payload: Distribution{Kind: "fixed", Params: []float64{0}},
+	}
+	chain := flag.String("chain", "both", "Chain of the transactions: eth, btc or both")
+	numTransactions := flag.Int("count", 1_000_000, "Transactions to generate per chain")
+	seed := flag.Int64("seed", 0, "Seed of the generator, 0 picks a random one")
+	format := flag.String("format", "json", "Output format: json, jsonl or binary")
+	perFile := flag.Int("per-file", 0, "Transactions per file, one file per block of that many; 0 writes a single file")
+	dir := flag.String("out", ".", "Directory the files are written to")
+	flag.Var(&shape.Inputs, "inputs", "Distribution of the inputs of a BTC transaction: fixed:n, uniform:a:b, poisson:lambda, geometric:p or lognormal:mu:sd")
+	flag.Var(&shape.Outputs, "outputs", "Distribution of the outputs of a BTC transaction, written like -inputs")
+	flag.Var(&shape.Payload, "payload", "Distribution of the payload bytes of a transaction, written like -inputs")
+	flag.Parse()
+
+	var chains []string
+	switch *chain {
+	case "eth", "btc":
+		chains = []string{*chain}
+	case "both":
+		chains = []string{"eth", "btc"}
+	default:
+		fmt.Println("Unknown chain:", *chain)
 		return
 	}
-	rng := mrand.New(mrand.NewSource(seed.Int64()))
-
-	numTransactions := 1_000_000
-
-	ethTransactions := make([]ETHTransaction, numTransactions)
-	btcTransactions := make([]BTCTransaction, numTransactions)
-
-	for i := 0; i < numTransactions; i++ {
-		ethTransactions[i] = randomETHTransaction(rng)
-		btcTransactions[i] = randomBTCTransaction(rng)
+	if *seed == 0 {
+		random, err := rand.Int(rand.Reader, big.NewInt(math.MaxInt64))
+		if err != nil {
+			fmt.Println("Error generating random seed:", err)
+			return
+		}
+		*seed = random.Int64()
 	}
-
-	ethFile, err := os.Create("eth_transactions.json")
-	if err != nil {
-		fmt.Println("Error creating ETH transactions file:", err)
-		return
+	fmt.Println("Seed:", *seed)
+	// Every chain draws from its own generator, so a seed reproduces the
+	// dataset of a chain whichever other chains are generated with it
+	rngs := make(map[string]*mrand.Rand)
+	for _, name := range chains {
+		hash := fnv.New64a()
+		hash.Write([]byte(name))
+		rngs[name] = mrand.New(mrand.NewSource(*seed ^ int64(hash.Sum64())))
 	}
-	defer ethFile.Close()
 
-	btcFile, err := os.Create("btc_transactions.json")
-	if err != nil {
-		fmt.Println("Error creating BTC transactions file:", err)
-		return
+	// Transactions go to disk one at a time, so the count is not bound by
+	// memory
+	writers := make(map[string]*DatasetWriter)
+	for _, name := range chains {
+		writer, err := NewDatasetWriter(*dir, name, *format, *perFile)
+		if err != nil {
+			fmt.Println("Error creating", name, "transactions file:", err)
+			return
+		}
+		writers[name] = writer
 	}
-	defer btcFile.Close()
-
-	ethEncoder := json.NewEncoder(ethFile)
-	btcEncoder := json.NewEncoder(btcFile)
-
-	if err := ethEncoder.Encode(ethTransactions); err != nil {
-		fmt.Println("Error encoding ETH transactions to JSON:", err)
-		return
+	for i := 0; i < *numTransactions; i++ {
+		for _, name := range chains {
+			var txn any
+			if name == "eth" {
+				txn = randomETHTransaction(rngs[name], shape)
+			} else {
+				txn = randomBTCTransaction(rngs[name], shape)
+			}
+			if err := writers[name].Write(txn); err != nil {
+				fmt.Println("Error writing", name, "transaction:", err)
+				return
+			}
+		}
 	}
-
-	if err := btcEncoder.Encode(btcTransactions); err != nil {
-		fmt.Println("Error encoding BTC transactions to JSON:", err)
-		return
+	for _, name := range chains {
+		if err := writers[name].Close(); err != nil {
+			fmt.Println("Error closing", name, "transactions file:", err)
+			return
+		}
+		fmt.Printf("Wrote %d %s transactions to %d files in %s\n", writers[name].written, name, writers[name].files, *dir)
 	}
-
-	fmt.Println("Successfully generated and wrote ETH and BTC transactions to files.")
 }
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+)
+
+// Output formats:
+//
+//	json     one JSON array per file, as read by the dataset loaders
+//	jsonl    one JSON transaction per line
+//	binary   transactions one after another, each a uvarint length and the
+//	         fields in order: strings and lists as a uvarint length and
+//	         their items, floats as 8 little endian bytes, integers as
+//	         uvarints, addresses as their 20 bytes and payloads as a
+//	         uvarint length and their raw bytes
+var formatExtensions = map[string]string{
+	"json":   "json",
+	"jsonl":  "jsonl",
+	"binary": "bin",
+}
+
+// DatasetWriter streams the transactions of one chain to disk, starting a
+// new file every perFile transactions when perFile is positive
+type DatasetWriter struct {
+	dir     string
+	chain   string
+	format  string
+	perFile int
+	written int // Transactions written to all files
+	files   int // Files opened
+	file    *os.File
+	buf     *bufio.Writer
+	inFile  int // Transactions written to the current file
+}
+
+func NewDatasetWriter(dir, chain, format string, perFile int) (*DatasetWriter, error) {
+	if _, ok := formatExtensions[format]; !ok {
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &DatasetWriter{dir: dir, chain: chain, format: format, perFile: perFile}, nil
+}
+
+func (w *DatasetWriter) path() string {
+	name := w.chain + "_transactions"
+	if w.perFile > 0 {
+		name = fmt.Sprintf("%s_%06d", name, w.files)
+	}
+	return filepath.Join(w.dir, name+"."+formatExtensions[w.format])
+}
+
+func (w *DatasetWriter) open() error {
+	file, err := os.Create(w.path())
+	if err != nil {
+		return err
+	}
+	w.file, w.buf, w.inFile = file, bufio.NewWriterSize(file, 1<<20), 0
+	w.files++
+	if w.format == "json" {
+		_, err = w.buf.WriteString("[")
+	}
+	return err
+}
+
+// closeFile finishes the current file
+func (w *DatasetWriter) closeFile() error {
+	if w.file == nil {
+		return nil
+	}
+	if w.format == "json" {
+		w.buf.WriteString("\n]\n")
+	}
+	err := w.buf.Flush()
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	w.file = nil
+	return err
+}
+
+// Write appends an ETHTransaction or a BTCTransaction
+func (w *DatasetWriter) Write(txn any) error {
+	if w.file != nil && w.perFile > 0 && w.inFile == w.perFile {
+		if err := w.closeFile(); err != nil {
+			return err
+		}
+	}
+	if w.file == nil {
+		if err := w.open(); err != nil {
+			return err
+		}
+	}
+	var err error
+	switch w.format {
+	case "binary":
+		var record []byte
+		if record, err = encodeBinary(txn); err == nil {
+			w.buf.Write(binary.AppendUvarint(nil, uint64(len(record))))
+			_, err = w.buf.Write(record)
+		}
+	default:
+		var data []byte
+		if data, err = json.Marshal(txn); err == nil {
+			if w.format == "json" {
+				// Separate the array items the way the jsonl lines are
+				if w.inFile > 0 {
+					w.buf.WriteString(",")
+				}
+				w.buf.WriteString("\n")
+			}
+			w.buf.Write(data)
+			if w.format == "jsonl" {
+				_, err = w.buf.WriteString("\n")
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+	w.inFile++
+	w.written++
+	return nil
+}
+
+func (w *DatasetWriter) Close() error {
+	return w.closeFile()
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+func appendFloat(buf []byte, f float64) []byte {
+	return binary.LittleEndian.AppendUint64(buf, math.Float64bits(f))
+}
+
+// appendPayload stores the hex payload as its raw bytes
+func appendPayload(buf []byte, payload string) ([]byte, error) {
+	raw, err := hex.DecodeString(payload)
+	if err != nil {
+		return nil, fmt.Errorf("bad payload: %v", err)
+	}
+	buf = binary.AppendUvarint(buf, uint64(len(raw)))
+	return append(buf, raw...), nil
+}
+
+func appendAddress(buf []byte, address string) ([]byte, error) {
+	raw, err := hex.DecodeString(address[2:])
+	if err != nil || len(raw) != 20 {
+		return nil, fmt.Errorf("bad address %q", address)
+	}
+	return append(buf, raw...), nil
+}
+
+func encodeBinary(txn any) ([]byte, error) {
+	var buf []byte
+	var err error
+	switch txn := txn.(type) {
+	case ETHTransaction:
+		if buf, err = appendAddress(buf, txn.From); err != nil {
+			return nil, err
+		}
+		if buf, err = appendAddress(buf, txn.To); err != nil {
+			return nil, err
+		}
+		buf = appendFloat(buf, txn.Value)
+		buf = appendFloat(buf, txn.GasPrice)
+		buf = binary.AppendUvarint(buf, txn.Nonce)
+		if buf, err = appendPayload(buf, txn.Data); err != nil {
+			return nil, err
+		}
+	case BTCTransaction:
+		buf = appendString(buf, txn.TxID)
+		buf = binary.AppendUvarint(buf, uint64(len(txn.Vin)))
+		for _, vin := range txn.Vin {
+			buf = appendString(buf, vin.TxID)
+			buf = binary.AppendUvarint(buf, uint64(vin.Vout))
+		}
+		buf = binary.AppendUvarint(buf, uint64(len(txn.Vout)))
+		for _, vout := range txn.Vout {
+			buf = appendFloat(buf, vout.Value)
+			buf = appendString(buf, vout.ScriptPubKey)
+		}
+		buf = appendFloat(buf, txn.Fee)
+		if buf, err = appendPayload(buf, txn.Data); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("cannot encode %T", txn)
+	}
+	return buf, nil
+}
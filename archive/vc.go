@@ -2,33 +2,104 @@ package main
 
 import (
 	"fmt"
-	"reflect"
 	"time"
 
 	"github.com/tendermint/tendermint/crypto/merkle"
+	tmcrypto "github.com/tendermint/tendermint/proto/tendermint/crypto"
 )
 
 // ###################################
 
-func CreateVectorCommitment(chunks []Chunk) ([]byte, []*merkle.Proof) {
-	leaves := make([][]byte, len(chunks))
+// VectorCommitment commits to the vector of coded chunks and opens single
+// positions. Proofs travel as opaque bytes, so the sync protocol does not
+// depend on the scheme.
+type VectorCommitment interface {
+	Name() string
+	// Commit commits to the chunk data and returns an opener for its positions
+	Commit(data [][]byte) ([]byte, VectorOpener, error)
+	// Verify checks a serialized proof that data sits at index
+	Verify(commitment []byte, index int, data []byte, proof []byte) error
+}
+
+// VectorOpener produces the serialized proof of one position of a commitment
+type VectorOpener interface {
+	Open(index int) ([]byte, error)
+}
+
+// vectorCommitment is the scheme of the run, shared by all nodes
+var vectorCommitment VectorCommitment
+
+// NewVectorCommitment returns the named scheme for vectors of up to n chunks
+func NewVectorCommitment(name string, n int) (VectorCommitment, error) {
+	switch name {
+	case "merkle":
+		return MerkleCommitment{}, nil
+	case "kzg":
+		// Local trusted setup, every node of the run shares it
+		return NewKZGSetup(n), nil
+	case "pedersen-list":
+		return NewPedersenCommitmentList()
+	}
+	return nil, fmt.Errorf("unknown vector commitment %q, expected merkle, kzg or pedersen-list", name)
+}
+
+func CreateVectorCommitment(chunks []Chunk) ([]byte, VectorOpener) {
+	data := make([][]byte, len(chunks))
 	for i, chunk := range chunks {
-		leaves[i] = chunk.Data
+		data[i] = chunk.Data
 	}
 
-	rootHash, proofs := merkle.ProofsFromByteSlices(leaves)
-	return rootHash, proofs
+	commitment, opener, err := vectorCommitment.Commit(data)
+	if err != nil {
+		panic(err)
+	}
+	return commitment, opener
 }
 
-func VerifyChunk(client *Client, commitment []byte, chunk Chunk, proof *merkle.Proof) bool {
-	// Size of proof and commitment in byte
-	proofSize := reflect.TypeOf(*proof).Size()
-	commitmentSize := reflect.TypeOf(commitment).Size()
-	fmt.Println("Size of proof and commitment in byte: ", proofSize, commitmentSize)
+func VerifyChunk(client *Client, commitment []byte, index int, chunk Chunk) bool {
+	// Size of proof and commitment in byte, as sent over the network
+	fmt.Println("Size of proof and commitment in byte: ", len(chunk.Proof), len(commitment))
 
 	// Capture the time for verification
 	start := time.Now()
-	err := proof.Verify(commitment, chunk.Data)
+	err := vectorCommitment.Verify(commitment, index, chunk.Data, chunk.Proof)
 	fmt.Println("Time taken for verification: ", time.Since(start))
 	return err == nil
 }
+
+// ###################################
+
+// MerkleCommitment is a tendermint merkle tree over the chunks. Proofs are
+// the protobuf encoding of merkle.Proof and grow with log N.
+type MerkleCommitment struct{}
+
+type merkleOpener []*merkle.Proof
+
+func (MerkleCommitment) Name() string { return "merkle" }
+
+func (MerkleCommitment) Commit(data [][]byte) ([]byte, VectorOpener, error) {
+	rootHash, proofs := merkle.ProofsFromByteSlices(data)
+	return rootHash, merkleOpener(proofs), nil
+}
+
+func (o merkleOpener) Open(index int) ([]byte, error) {
+	if index < 0 || index >= len(o) {
+		return nil, fmt.Errorf("index %d out of range", index)
+	}
+	return o[index].ToProto().Marshal()
+}
+
+func (MerkleCommitment) Verify(commitment []byte, index int, data []byte, proof []byte) error {
+	var pb tmcrypto.Proof
+	if err := pb.Unmarshal(proof); err != nil {
+		return err
+	}
+	p, err := merkle.ProofFromProto(&pb)
+	if err != nil {
+		return err
+	}
+	if p.Index != int64(index) {
+		return fmt.Errorf("proof opens index %d, expected %d", p.Index, index)
+	}
+	return p.Verify(commitment, data)
+}
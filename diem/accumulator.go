@@ -0,0 +1,214 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/bits"
+	"strconv"
+)
+
+// HashValue is a sha256 digest. It travels as a hex string in JSON.
+type HashValue [sha256.Size]byte
+
+func (h HashValue) String() string {
+	return hex.EncodeToString(h[:])
+}
+
+func (h HashValue) MarshalText() ([]byte, error) {
+	return []byte(h.String()), nil
+}
+
+func (h *HashValue) UnmarshalText(text []byte) error {
+	if hex.DecodedLen(len(text)) != len(h) {
+		return fmt.Errorf("hash of %d hex digits", len(text))
+	}
+	_, err := hex.Decode(h[:], text)
+	return err
+}
+
+// Domain separation as in Diem, every hashed type gets its own prefix
+const (
+	transactionHashPrefix = "DIEM::Transaction"
+	infoHashPrefix        = "DIEM::TransactionInfo"
+	accumulatorHashPrefix = "DIEM::TransactionAccumulator"
+)
+
+// placeholderHash stands for subtrees with no leaves yet, it is the literal
+// "ACCUMULATOR_PLACEHOLDER_HASH" padded with zeros like in Diem
+var placeholderHash = func() HashValue {
+	var h HashValue
+	copy(h[:], "ACCUMULATOR_PLACEHOLDER_HASH")
+	return h
+}()
+
+// hashWithPrefix hashes the length prefixed parts under a domain prefix
+func hashWithPrefix(prefix string, parts ...[]byte) HashValue {
+	hasher := sha256.New()
+	hasher.Write([]byte(prefix))
+	for _, part := range parts {
+		hasher.Write(binary.AppendUvarint(nil, uint64(len(part))))
+		hasher.Write(part)
+	}
+	var h HashValue
+	hasher.Sum(h[:0])
+	return h
+}
+
+func HashTransaction(txn Transaction) HashValue {
+	return hashWithPrefix(transactionHashPrefix, []byte(txn.ID), []byte(txn.Content),
+		[]byte(txn.Signature), []byte(strconv.FormatInt(txn.Timestamp, 10)))
+}
+
+func NewTransactionInfo(txn Transaction) TransactionInfo {
+	return TransactionInfo{TransactionHash: HashTransaction(txn)}
+}
+
+// Hash is the accumulator leaf of the transaction
+func (info TransactionInfo) Hash() HashValue {
+	return hashWithPrefix(infoHashPrefix, info.TransactionHash[:])
+}
+
+func innerHash(left, right HashValue) HashValue {
+	return hashWithPrefix(accumulatorHashPrefix, left[:], right[:])
+}
+
+// treeHeight is the number of levels above the leaves of an accumulator
+// with numLeaves leaves
+func treeHeight(numLeaves uint64) int {
+	if numLeaves <= 1 {
+		return 0
+	}
+	return bits.Len64(numLeaves - 1)
+}
+
+// Accumulator is Diem's append-only Merkle accumulator. Leaves fill a
+// complete binary tree from the left and missing subtrees hash to the
+// placeholder. Frozen[k] holds the roots of the full subtrees of height k in
+// order, so appending only hashes along one path.
+type Accumulator struct {
+	Frozen    [][]HashValue
+	NumLeaves uint64
+}
+
+func NewAccumulator() *Accumulator {
+	return &Accumulator{}
+}
+
+// Append adds a leaf and freezes the subtrees it completes
+func (a *Accumulator) Append(leaf HashValue) {
+	node := leaf
+	for k := 0; ; k++ {
+		if k == len(a.Frozen) {
+			a.Frozen = append(a.Frozen, nil)
+		}
+		a.Frozen[k] = append(a.Frozen[k], node)
+		n := len(a.Frozen[k])
+		if n%2 == 1 {
+			break
+		}
+		node = innerHash(a.Frozen[k][n-2], a.Frozen[k][n-1])
+	}
+	a.NumLeaves++
+}
+
+// RootHash combines the frozen subtrees with placeholders on the right
+func (a *Accumulator) RootHash() HashValue {
+	if a.NumLeaves == 0 {
+		return placeholderHash
+	}
+	height := treeHeight(a.NumLeaves)
+	var right HashValue
+	partial := false
+	for k := 0; k < height; k++ {
+		if len(a.Frozen[k])%2 == 1 {
+			sibling := placeholderHash
+			if partial {
+				sibling = right
+			}
+			right = innerHash(a.Frozen[k][len(a.Frozen[k])-1], sibling)
+			partial = true
+		} else if partial {
+			right = innerHash(right, placeholderHash)
+		}
+	}
+	if partial {
+		return right
+	}
+	return a.Frozen[height][0]
+}
+
+// nodeHash returns the hash of the index-th node at level k
+func (a *Accumulator) nodeHash(k int, index uint64) HashValue {
+	if index<<k >= a.NumLeaves {
+		return placeholderHash
+	}
+	if index < uint64(len(a.Frozen[k])) {
+		return a.Frozen[k][index]
+	}
+	return innerHash(a.nodeHash(k-1, 2*index), a.nodeHash(k-1, 2*index+1))
+}
+
+// RangeProof proves the leaves [first, first+count) with the siblings left
+// of the path to the first leaf and right of the path to the last one,
+// bottom to top
+func (a *Accumulator) RangeProof(first, count uint64) (TransactionAccumulatorRangeProof, error) {
+	var proof TransactionAccumulatorRangeProof
+	if count == 0 || first+count > a.NumLeaves {
+		return proof, fmt.Errorf("range [%d, %d) outside %d leaves", first, first+count, a.NumLeaves)
+	}
+	left, right := first, first+count-1
+	for k := 0; k < treeHeight(a.NumLeaves); k++ {
+		if left%2 == 1 {
+			proof.LeftSiblings = append(proof.LeftSiblings, a.nodeHash(k, left-1))
+		}
+		if right%2 == 0 {
+			proof.RightSiblings = append(proof.RightSiblings, a.nodeHash(k, right+1))
+		}
+		left, right = left/2, right/2
+	}
+	return proof, nil
+}
+
+// Verify checks that leaves sit at [first, first+len(leaves)) of an
+// accumulator with numLeaves leaves and the given root
+func (proof TransactionAccumulatorRangeProof) Verify(root HashValue, numLeaves, first uint64, leaves []HashValue) error {
+	count := uint64(len(leaves))
+	if count == 0 || first+count > numLeaves {
+		return fmt.Errorf("range [%d, %d) outside %d leaves", first, first+count, numLeaves)
+	}
+	level := append([]HashValue(nil), leaves...)
+	left, right := proof.LeftSiblings, proof.RightSiblings
+	for k := 0; k < treeHeight(numLeaves); k++ {
+		if first%2 == 1 {
+			if len(left) == 0 {
+				return errors.New("range proof is missing a left sibling")
+			}
+			level = append([]HashValue{left[0]}, level...)
+			left = left[1:]
+			first--
+		}
+		if len(level)%2 == 1 {
+			if len(right) == 0 {
+				return errors.New("range proof is missing a right sibling")
+			}
+			level = append(level, right[0])
+			right = right[1:]
+		}
+		next := make([]HashValue, len(level)/2)
+		for j := range next {
+			next[j] = innerHash(level[2*j], level[2*j+1])
+		}
+		level = next
+		first /= 2
+	}
+	if len(left) != 0 || len(right) != 0 {
+		return errors.New("range proof has extra siblings")
+	}
+	if level[0] != root {
+		return errors.New("range proof does not match the accumulator root")
+	}
+	return nil
+}
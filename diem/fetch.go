@@ -0,0 +1,236 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Parallel range fetching: the syncing node first learns the signed ledger
+// info, then splits [0, Version) into ranges of CHUNK_SIZE transactions and
+// fetches them from FETCH_PEERS peers at once. A range that fails goes back
+// to the queue for another peer.
+
+func (n *Node) blacklist(peer int) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.BlackList[peer] = true
+}
+
+// claimPeer picks a random peer that is neither blacklisted nor serving
+// another range worker, -1 when there is none
+func (n *Node) claimPeer() int {
+	for {
+		peer := n.selectRandomPeer()
+		if peer < 0 {
+			return peer
+		}
+		n.lock.Lock()
+		if !n.Busy[peer] {
+			n.Busy[peer] = true
+			n.lock.Unlock()
+			return peer
+		}
+		n.lock.Unlock()
+	}
+}
+
+func (n *Node) releasePeer(peer int) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	delete(n.Busy, peer)
+}
+
+func (n *Node) processLedgerInfoRequest(request *ChunkRequest, conn net.Conn) {
+	committed := n.Network.committedBlock(request.BlockID, n.generateBlockForRequest)
+	if n.IsByzantine {
+		// DOING NOTHING
+		return
+	}
+	message := &Message{
+		From:    n.ID,
+		To:      request.NodeID,
+		Type:    "ledger_info",
+		Content: committed.LedgerInfo,
+	}
+	responseBytes, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("Error marshaling ledger info: %v", err)
+		return
+	}
+	time.Sleep(NETWORK_DELAY)
+	if _, err := conn.Write(responseBytes); err != nil {
+		log.Printf("Error writing ledger info to connection: %v", err)
+	}
+}
+
+// fetchLedgerInfo asks random peers for the ledger info of the block until
+// one sends a properly signed one
+func (n *Node) fetchLedgerInfo(blockID int) bool {
+	requestData, err := json.Marshal(&Message{
+		From:    n.ID,
+		To:      -1,
+		Type:    "ledger_info_request",
+		Content: &ChunkRequest{NodeID: n.ID, BlockID: blockID},
+	})
+	if err != nil {
+		log.Fatalf("Error marshaling request: %v", err)
+	}
+	for {
+		peer := n.selectRandomPeer()
+		if peer < 0 {
+			return false
+		}
+		conn, err := net.Dial("tcp", n.Peers[peer])
+		if err != nil {
+			log.Fatalf("Error connecting to peer: %v", err)
+		}
+		var message struct {
+			Type    string
+			Content LedgerInfoWithSignatures
+		}
+		_, err = conn.Write(requestData)
+		if err == nil {
+			conn.SetReadDeadline(time.Now().Add(n.Timeouts.idle()))
+			err = json.NewDecoder(conn).Decode(&message)
+		}
+		conn.Close()
+		if err == nil && message.Type == "ledger_info" {
+			n.lock.Lock()
+			err = n.trustLedgerInfo(&message.Content)
+			n.lock.Unlock()
+			if err == nil {
+				return true
+			}
+		}
+		log.Printf("Node %d got no ledger info from node %d: %v", n.ID, peer, err)
+		n.blacklist(peer)
+	}
+}
+
+// fetchRanges fetches the block range by range from several peers at once
+func (n *Node) fetchRanges(blockID int) {
+	if !n.fetchLedgerInfo(blockID) {
+		log.Printf("Node %d found no peer with the ledger info of block %d", n.ID, blockID)
+		return
+	}
+	total := int(n.LedgerInfo.LedgerInfo.Version)
+	ranges := make(chan int, (total+CHUNK_SIZE-1)/CHUNK_SIZE)
+	for first := 0; first < total; first += CHUNK_SIZE {
+		ranges <- first
+	}
+	fmt.Printf("Node %d fetching %d ranges from %d peers\n", n.ID, len(ranges), FETCH_PEERS)
+
+	var remaining sync.WaitGroup
+	remaining.Add(len(ranges))
+	done := make(chan struct{})
+	go func() {
+		remaining.Wait()
+		close(done)
+	}()
+
+	var workers sync.WaitGroup
+	for w := 0; w < FETCH_PEERS; w++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			n.rangeWorker(blockID, total, ranges, done, &remaining)
+		}()
+	}
+	workers.Wait()
+}
+
+// rangeWorker fetches ranges off the queue from one peer over a single
+// connection and moves to another peer when a range fails
+func (n *Node) rangeWorker(blockID, total int, ranges chan int, done chan struct{}, remaining *sync.WaitGroup) {
+	peer := n.claimPeer()
+	var conn net.Conn
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+		if peer >= 0 {
+			n.releasePeer(peer)
+		}
+	}()
+	for peer >= 0 {
+		select {
+		case <-done:
+			return
+		case first := <-ranges:
+			if conn == nil {
+				var err error
+				if conn, err = net.Dial("tcp", n.Peers[peer]); err != nil {
+					log.Fatalf("Error connecting to peer: %v", err)
+				}
+			}
+			want := &versionRange{First: first, Count: min(CHUNK_SIZE, total-first)}
+			if n.fetchRange(conn, peer, blockID, want) {
+				remaining.Done()
+				continue
+			}
+			fmt.Printf("Node %d reassigning range %d after node %d failed\n", n.ID, first, peer)
+			ranges <- first
+			n.lock.Lock()
+			n.Metrics.ReassignedRanges++
+			n.lock.Unlock()
+			conn.Close()
+			conn = nil
+			n.releasePeer(peer)
+			peer = n.claimPeer()
+		}
+	}
+}
+
+// versionRange is the run of Count transactions from version First on
+type versionRange struct {
+	First int
+	Count int
+}
+
+// fetchRange requests the transactions of the range. Transaction IDs are
+// their versions in the generated blocks, so the range is named by the ID
+// of its first transaction.
+func (n *Node) fetchRange(conn net.Conn, peer, blockID int, want *versionRange) bool {
+	requestData, err := json.Marshal(&Message{
+		From: n.ID,
+		To:   peer,
+		Type: "request",
+		Content: &ChunkRequest{
+			NodeID:         n.ID,
+			BlockID:        blockID,
+			TransactionIDs: []string{strconv.Itoa(want.First)},
+			RequestSize:    want.Count,
+		},
+	})
+	if err != nil {
+		log.Fatalf("Error marshaling request: %v", err)
+	}
+	if _, err := conn.Write(requestData); err != nil {
+		log.Printf("Error writing request to connection: %v", err)
+		return false
+	}
+	return n.readResponse(conn, peer, want)
+}
+
+// matchesRange checks that a response holds the wanted range, if any. A
+// peer answering with another range, proven or not, is blacklisted.
+func (n *Node) matchesRange(response *ChunkResponse, want *versionRange, peer, size int) bool {
+	if want == nil || (response.FirstVersion == want.First && len(response.Transactions) == want.Count) {
+		return true
+	}
+	log.Printf("Node %d blacklisting node %d for sending %d transactions from version %d instead of %d from %d",
+		n.ID, peer, len(response.Transactions), response.FirstVersion, want.Count, want.First)
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.Metrics.TotalChunks++
+	n.Metrics.FailedChunks++
+	n.Metrics.ReceivedBytes += size
+	n.Metrics.WastedBytes += size
+	n.BlackList[peer] = true
+	return false
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// LedgerInfo commits to the ledger after a block: Version transactions
+// under the accumulator root
+type LedgerInfo struct {
+	BlockID         int       // Block the ledger info closes
+	BlockHash       string    // Hash of that block
+	Version         uint64    // Number of transactions in the accumulator
+	AccumulatorRoot HashValue // Root of the transaction accumulator
+}
+
+// LedgerInfoWithSignatures is a ledger info signed by a quorum of validators
+type LedgerInfoWithSignatures struct {
+	LedgerInfo LedgerInfo
+	Signatures map[int][]byte // Signature of each validator, by node ID
+}
+
+const ledgerInfoPrefix = "DIEM::LedgerInfo"
+
+// signingBytes is what the validators sign
+func (li LedgerInfo) signingBytes() []byte {
+	data, err := json.Marshal(li)
+	if err != nil {
+		panic(err)
+	}
+	return append([]byte(ledgerInfoPrefix), data...)
+}
+
+// ValidatorSet is the epoch state the syncing node trusts
+type ValidatorSet struct {
+	PublicKeys map[int]ed25519.PublicKey
+	Quorum     int // Signatures needed, 2f+1 of 3f+1
+}
+
+// quorum is the number of signatures a ledger info needs among numNodes
+// validators, 2f+1 of 3f+1
+func quorum(numNodes int) int {
+	return 2*((numNodes-1)/3) + 1
+}
+
+// NewValidatorSet makes a key pair for every node
+func NewValidatorSet(numNodes int) (*ValidatorSet, map[int]ed25519.PrivateKey) {
+	set := &ValidatorSet{
+		PublicKeys: make(map[int]ed25519.PublicKey),
+		Quorum:     quorum(numNodes),
+	}
+	privateKeys := make(map[int]ed25519.PrivateKey)
+	for i := 0; i < numNodes; i++ {
+		public, private, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			panic(err)
+		}
+		set.PublicKeys[i] = public
+		privateKeys[i] = private
+	}
+	return set, privateKeys
+}
+
+// Verify checks that a quorum of distinct validators signed the ledger info
+func (set *ValidatorSet) Verify(li *LedgerInfoWithSignatures) error {
+	message := li.LedgerInfo.signingBytes()
+	valid := 0
+	for id, signature := range li.Signatures {
+		public, ok := set.PublicKeys[id]
+		if ok && ed25519.Verify(public, message, signature) {
+			valid++
+		}
+	}
+	if valid < set.Quorum {
+		return fmt.Errorf("ledger info has %d valid signatures, quorum is %d", valid, set.Quorum)
+	}
+	return nil
+}
+
+// CommittedBlock is a block the validators agreed on, with the accumulator
+// over its transactions and the signed ledger info
+type CommittedBlock struct {
+	Block       *Block
+	Accumulator *Accumulator
+	LedgerInfo  *LedgerInfoWithSignatures
+	Versions    map[string]int // Version of each transaction, by ID
+}
+
+// CommitBlock builds the accumulator of the block and has every honest
+// validator sign the resulting ledger info
+func (network *Network) CommitBlock(block *Block) *CommittedBlock {
+	accumulator := NewAccumulator()
+	versions := make(map[string]int, len(block.Transactions))
+	for i, txn := range block.Transactions {
+		accumulator.Append(NewTransactionInfo(txn).Hash())
+		versions[txn.ID] = i
+	}
+	li := &LedgerInfoWithSignatures{
+		LedgerInfo: LedgerInfo{
+			BlockID:         block.ID,
+			BlockHash:       block.Hash,
+			Version:         accumulator.NumLeaves,
+			AccumulatorRoot: accumulator.RootHash(),
+		},
+		Signatures: make(map[int][]byte),
+	}
+	message := li.LedgerInfo.signingBytes()
+	for id, node := range network.Nodes {
+		if !node.IsByzantine {
+			li.Signatures[id] = ed25519.Sign(node.SigningKey, message)
+		}
+	}
+	return &CommittedBlock{Block: block, Accumulator: accumulator, LedgerInfo: li, Versions: versions}
+}
+
+// committedBlock returns the agreed block with the ID, generating and
+// committing it on first use so every peer serves the same transactions
+func (network *Network) committedBlock(blockID int, generate func(int) *Block) *CommittedBlock {
+	network.lock.Lock()
+	defer network.lock.Unlock()
+	if committed, ok := network.Committed[blockID]; ok {
+		return committed
+	}
+	committed := network.CommitBlock(generate(blockID))
+	network.Committed[blockID] = committed
+	return committed
+}
+
+// trustLedgerInfo checks the signatures of a ledger info for the block being
+// synced the first time it is seen and then holds the node to it
+func (n *Node) trustLedgerInfo(li *LedgerInfoWithSignatures) error {
+	if li == nil {
+		return errors.New("response carries no ledger info")
+	}
+	if li.LedgerInfo.BlockID != n.Ledger.BlockID {
+		return fmt.Errorf("ledger info is for block %d, not block %d", li.LedgerInfo.BlockID, n.Ledger.BlockID)
+	}
+	if n.LedgerInfo != nil {
+		if li.LedgerInfo != n.LedgerInfo.LedgerInfo {
+			return errors.New("ledger info differs from the trusted one")
+		}
+		return nil
+	}
+	if err := n.Network.Validators.Verify(li); err != nil {
+		return err
+	}
+	n.LedgerInfo = li
+	return nil
+}
@@ -1,23 +1,32 @@
 package main
 
 import (
+	"crypto/ed25519"
+	"flag"
 	"fmt"
+	"log"
 	"net"
+	"sync"
 	"time"
 
 	"golang.org/x/exp/rand"
 )
 
 const (
-	TXN_SIZE           = 1_000_000
-	CHUNK_SIZE         = 7936 // 1000000/(157777866/1250000)
-	N                  = 30
-	faultyNodesCounter = 10
-	BUFFER_SIZE        = 65536
-	NETWORK_DELAY      = 300 * time.Millisecond
+	BUFFER_SIZE = 65536
 )
 
-var F = make(map[int]bool)
+var (
+	TXN_SIZE           int           // Transactions in a block
+	CHUNK_SIZE         int           // Transactions in a chunk, worked out from the bandwidth when 0
+	N                  int           // Number of nodes, the syncing one included
+	faultyNodesCounter int           // Number of faulty nodes
+	UPLOAD_BANDWIDTH   int           // Upload bandwidth of a peer in bytes per second
+	NETWORK_DELAY      time.Duration // Delay before each response
+	F                  = make(map[int]bool)
+	FETCH_MODE         string // single streams the block from one peer, parallel splits it into ranges
+	FETCH_PEERS        int    // Peers fetched from at once in parallel mode
+)
 
 type Transaction struct {
 	ID        string // Unique identifier for the transaction
@@ -39,21 +48,29 @@ type Node struct {
 	ID            int    // Unique identifier for the node
 	Address       string // TCP address for the node
 	Listener      net.Listener
-	Blockchain    []*Block       // Dynamic array of blocks representing the node's current blockchain
-	Network       *Network       // Reference to the network for communications
-	IsByzantine   bool           // Indicates whether the node exhibits Byzantine behavior
-	Peers         map[int]string // Map of peer nodes for direct referencing and messaging
-	BlockHeight   int            // Current height of the blockchain this node maintains
-	ConsensusRole string         // Role of the node in the consensus process, e.g., proposer, validator
-	Metrics       *SyncMetrics   // Metrics for tracking synchronization performance
-	BlackList     map[int]bool   // List of nodes to ignore during synchronization
-
+	Blockchain    []*Block                  // Dynamic array of blocks representing the node's current blockchain
+	Network       *Network                  // Reference to the network for communications
+	IsByzantine   bool                      // Indicates whether the node exhibits Byzantine behavior
+	Peers         map[int]string            // Map of peer nodes for direct referencing and messaging
+	BlockHeight   int                       // Current height of the blockchain this node maintains
+	ConsensusRole string                    // Role of the node in the consensus process, e.g., proposer, validator
+	Metrics       *SyncMetrics              // Metrics for tracking synchronization performance
+	BlackList     map[int]bool              // List of nodes to ignore during synchronization
+	SigningKey    ed25519.PrivateKey        // Validator key the node signs ledger infos with
+	LedgerInfo    *LedgerInfoWithSignatures // Ledger info the syncing node verified and syncs to
+	Ledger        *LocalLedger              // Block being rebuilt by the syncing node
+	Busy          map[int]bool              // Peers serving a range worker
+	Timeouts      AdaptiveTimeout           // Read timeouts learned from the responses so far
+	lock          sync.Mutex
 }
 
 type Network struct {
-	Nodes    map[int]*Node         // Map of all nodes indexed by their ID for quick access
-	Latency  map[int]map[int]int   // Matrix to simulate network latency between nodes
-	Channels map[int]chan *Message // Channels for node-to-node communication, mapped by node ID
+	Nodes      map[int]*Node           // Map of all nodes indexed by their ID for quick access
+	Latency    map[int]map[int]int     // Matrix to simulate network latency between nodes
+	Channels   map[int]chan *Message   // Channels for node-to-node communication, mapped by node ID
+	Validators *ValidatorSet           // Public keys of the validators, trusted by every node
+	Committed  map[int]*CommittedBlock // Blocks agreed on by the validators, by block ID
+	lock       sync.Mutex
 }
 
 type Message struct {
@@ -68,35 +85,47 @@ type ChunkRequest struct {
 	BlockID        int      // Identifier of the block from which data is needed
 	TransactionIDs []string // Specific transaction IDs requested, if not all transactions are needed
 	RequestSize    int      // Number of transactions requested in each chunk
+	StartVersion   int      // Version the whole block stream starts at, to resume an interrupted one
 }
 
 type ChunkResponse struct {
-	NodeID       int           // ID of the responding node
-	BlockID      int           // Identifier of the block from which the chunk is derived
-	Transactions []Transaction // The transactions that are included in the chunk
-	Proof        []string      // Cryptographic proof validating the transactions
-	Success      bool          // Indicates if the response is successfully processed or not
-	ErrorMessage string        // In case of an error, contains the error message
+	NodeID       int                              // ID of the responding node
+	BlockID      int                              // Identifier of the block from which the chunk is derived
+	FirstVersion int                              // Index of the first transaction of the chunk in the block
+	Transactions []Transaction                    // The transactions that are included in the chunk
+	Proof        TransactionAccumulatorRangeProof // Range proof of the transactions against the accumulator root
+	LedgerInfo   *LedgerInfoWithSignatures        // Signed accumulator root the proof is checked against
+	Success      bool                             // Indicates if the response is successfully processed or not
+	ErrorMessage string                           // In case of an error, contains the error message
 }
 
 type SyncMetrics struct {
-	NodeID            int           // ID of the node for which metrics are being tracked
-	StartTime         time.Time     // Time when the first chunk request was sent
-	EndTime           time.Time     // Time when the last chunk was successfully verified and integrated
-	TotalTransactions int           // Total number of transactions received
-	TotalChunks       int           // Total number of chunks received
-	SuccessfulChunks  int           // Number of successfully verified chunks
-	FailedChunks      int           // Number of chunks that failed verification
-	TotalDuration     time.Duration // Total time taken for the synchronization process
-	VerificationTime  time.Duration // Time taken to verify all chunks
+	NodeID                  int           // ID of the node for which metrics are being tracked
+	StartTime               time.Time     // Time when the first chunk request was sent
+	EndTime                 time.Time     // Time when the last chunk was successfully verified and integrated
+	TotalTransactions       int           // Total number of transactions received
+	TotalChunks             int           // Total number of chunks received
+	SuccessfulChunks        int           // Number of successfully verified chunks
+	FailedChunks            int           // Number of chunks that failed verification
+	TotalDuration           time.Duration // Total time taken for the synchronization process
+	VerificationTime        time.Duration // Time taken to verify all chunks
+	Gaps                    int           // Chunks that arrived before the transactions preceding them
+	OverlappingTransactions int           // Transactions received again after being integrated
+	ReassignedRanges        int           // Ranges handed to another peer after a failure
+	Resumes                 int           // Streams continued from the verified version on another peer
+	ReceivedBytes           int           // Bytes of responses received
+	WastedBytes             int           // Received bytes that added no transaction: cut off, rejected or fetched again
+	SilentPeers             int           // Peers blacklisted for sending nothing before the deadline
+	SlowPeers               int           // Peers left because a response stalled midway
 }
 
 type TransactionAccumulatorRangeProof struct {
-	LeftSiblings []string // Left siblings in the Merkle tree
+	LeftSiblings  []HashValue // Left siblings of the path to the first leaf, bottom to top
+	RightSiblings []HashValue // Right siblings of the path to the last leaf, bottom to top
 }
 
 type TransactionInfo struct {
-	TransactionHash string // Hash of the transaction
+	TransactionHash HashValue // Hash of the transaction
 }
 
 // Generate faulty nodes - output an array of faulty nodes which are randomly selected
@@ -110,6 +139,42 @@ func faultyNodesDriver(count int) []int {
 	return faultyNodes
 }
 func main() {
+	flag.IntVar(&N, "N", 29, "Number of nodes serving the block, the syncing node comes on top")
+	flag.IntVar(&faultyNodesCounter, "f", 10, "Number of faulty nodes")
+	flag.IntVar(&TXN_SIZE, "txs", 1_000_000, "Transactions in the block")
+	flag.IntVar(&CHUNK_SIZE, "chunk", 0, "Transactions in a chunk, 0 sizes a chunk to one second of upload")
+	flag.IntVar(&UPLOAD_BANDWIDTH, "upload", 1250000, "Upload bandwidth of a peer in bytes per second")
+	flag.DurationVar(&NETWORK_DELAY, "delay", 300*time.Millisecond, "Delay before each response")
+	flag.StringVar(&FETCH_MODE, "mode", "single", "Fetch mode: single streams the block from one peer, parallel splits it across peers")
+	flag.IntVar(&FETCH_PEERS, "peers", 10, "Peers fetched from at once in parallel mode")
+	flag.StringVar(&DATASET, "dataset", "", "ETH or BTC transactions in JSON to fill the block with, generated ones when empty")
+	flag.IntVar(&BLOCK_BYTES, "block-bytes", 0, "Target size of the dataset block in bytes, 0 takes the whole dataset")
+	flag.Parse()
+	N++
+	// Only honest validators sign, so they alone must make a quorum
+	if maxFaulty := N - quorum(N); faultyNodesCounter < 0 || faultyNodesCounter > maxFaulty {
+		log.Fatalf("f must be between 0 and %d, %d honest signers make the quorum of %d nodes", maxFaulty, quorum(N), N)
+	}
+	if TXN_SIZE < 1 || UPLOAD_BANDWIDTH < 1 || FETCH_PEERS < 1 {
+		log.Fatal("txs, upload and peers must be positive")
+	}
+	if CHUNK_SIZE < 0 {
+		log.Fatal("chunk must not be negative")
+	}
+	if FETCH_MODE != "single" && FETCH_MODE != "parallel" {
+		log.Fatalf("Unknown mode %q, expected single or parallel", FETCH_MODE)
+	}
+	loadDataset()
+	// Size of a single transaction in bytes
+	fmt.Printf("Size of a single transaction: %d bytes\n", SizeOfOneTransaction())
+	// Size of the entire file in bytes
+	fileSize := SizeOfTheFile()
+	fmt.Printf("Size of the entire file: %d bytes\n", fileSize)
+	if CHUNK_SIZE == 0 {
+		// As many transactions as a peer uploads in a second
+		CHUNK_SIZE = max(1, TXN_SIZE*UPLOAD_BANDWIDTH/fileSize)
+	}
+	fmt.Printf("Size of a chunk: %d txs\n", CHUNK_SIZE)
 	faultyNodes := faultyNodesDriver(faultyNodesCounter)
 	fmt.Println("Faulty nodes:", faultyNodes)
 	InitializeAdversary(faultyNodes)
@@ -18,8 +18,11 @@ func InitializeAdversary(faultyNode []int) {
 
 func InitializeNetwork(numNodes int, startingPort int) *Network {
 	network := &Network{
-		Nodes: make(map[int]*Node),
+		Nodes:     make(map[int]*Node),
+		Committed: make(map[int]*CommittedBlock),
 	}
+	validators, signingKeys := NewValidatorSet(numNodes)
+	network.Validators = validators
 	for i := 0; i < numNodes; i++ {
 		address := fmt.Sprintf("localhost:%d", startingPort+i)
 		listener, err := net.Listen("tcp", address)
@@ -40,6 +43,9 @@ func InitializeNetwork(numNodes int, startingPort int) *Network {
 			Blockchain:  make([]*Block, 0),
 			IsByzantine: byzantine,
 			BlackList:   make(map[int]bool),
+			Busy:        make(map[int]bool),
+			Network:     network,
+			SigningKey:  signingKeys[i],
 		}
 		network.Nodes[i] = node
 	}
@@ -98,26 +104,56 @@ func (n *Node) handleMessage(message Message, conn net.Conn) {
 
 		fmt.Println("Received chunk request from node", request.NodeID)
 		n.processChunkRequest(&request, conn)
+	case "ledger_info_request":
+		var request ChunkRequest
+		contentBytes, err := json.Marshal(message.Content)
+		if err != nil {
+			log.Printf("Error marshaling content: %v", err)
+			return
+		}
+		if err := json.Unmarshal(contentBytes, &request); err != nil {
+			log.Printf("Error unmarshaling ledger info request: %v", err)
+			return
+		}
+		n.processLedgerInfoRequest(&request, conn)
 	}
 }
 
-func (n *Node) readResponse(conn net.Conn, connectedPeer int) bool {
+// readResponse reads the responses to a request until the last one. With a
+// wanted range every response must hold exactly that range.
+func (n *Node) readResponse(conn net.Conn, connectedPeer int, want *versionRange) bool {
 	var buf [BUFFER_SIZE]byte
 	var accumulatedData bytes.Buffer
+	waitStart := time.Now()
+	var firstByte time.Time
+	first := true
 
 	for {
-		conn.SetReadDeadline(time.Now().Add(10 * time.Second)) // Reset deadline before each read
+		// Reset deadline before each read, a response under way only has to
+		// keep the observed throughput going
+		started := accumulatedData.Len() > 0
+		timeout := n.Timeouts.idle()
+		if started {
+			timeout = n.Timeouts.transfer()
+		}
+		conn.SetReadDeadline(time.Now().Add(timeout))
 		length, err := conn.Read(buf[:])
 		if err != nil {
-			if err != io.EOF {
+			// The stream ended before its last response, a partial one is lost
+			n.countWaste(accumulatedData.Len())
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				n.timedOut(connectedPeer, started, timeout)
+			} else if err != io.EOF {
 				log.Printf("Node %d error reading response: %v", n.ID, err)
-				n.BlackList[connectedPeer] = true
-				return false
+				n.blacklist(connectedPeer)
 			}
-			break
+			return false
 		}
 
 		// fmt.Printf("Node %d read %d bytes\n", n.ID, length)
+		if !started {
+			firstByte = time.Now()
+		}
 		accumulatedData.Write(buf[:length])
 
 		// Check if we have a full JSON object
@@ -131,6 +167,7 @@ func (n *Node) readResponse(conn net.Conn, connectedPeer int) bool {
 			var message Message
 			if err := json.Unmarshal(fullMessage, &message); err != nil {
 				log.Printf("Node %d error unmarshaling response: %v", n.ID, err)
+				n.countWaste(len(fullMessage))
 				continue
 			}
 			// fmt.Printf("Node %d received message: %v\n", n.ID, message)
@@ -146,7 +183,28 @@ func (n *Node) readResponse(conn net.Conn, connectedPeer int) bool {
 					log.Printf("Node %d error unmarshaling chunk response: %v", n.ID, err)
 					continue
 				}
-				n.handleChunkResponse(&response)
+				if !response.Success {
+					// Requests are built from the verified ledger, so an honest
+					// peer has no reason to refuse one
+					log.Printf("Node %d blacklisting node %d for an error: %s", n.ID, connectedPeer, response.ErrorMessage)
+					n.countWaste(len(fullMessage))
+					n.blacklist(connectedPeer)
+					return false
+				}
+				if !n.matchesRange(&response, want, connectedPeer, len(fullMessage)) {
+					return false
+				}
+				if !n.handleChunkResponse(&response, connectedPeer, len(fullMessage)) {
+					return false
+				}
+				// The first response of a stream comes right after the request,
+				// the peer paces the rest, so only the gaps between them count
+				if !first || message.Type == "last_response" {
+					n.Timeouts.observeWait(firstByte.Sub(waitStart))
+				}
+				n.Timeouts.observeTransfer(len(fullMessage), time.Since(firstByte))
+				waitStart, firstByte = time.Now(), time.Now()
+				first = false
 				if message.Type == "last_response" {
 					break
 				}
@@ -156,6 +214,21 @@ func (n *Node) readResponse(conn net.Conn, connectedPeer int) bool {
 	return true
 }
 
+// timedOut handles a peer that missed its read deadline. Silence means
+// the peer is Byzantine, a response under way means it is only slow.
+func (n *Node) timedOut(peer int, started bool, timeout time.Duration) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	if started {
+		log.Printf("Node %d leaving slow node %d after %v without data", n.ID, peer, timeout)
+		n.Metrics.SlowPeers++
+		return
+	}
+	log.Printf("Node %d blacklisting node %d, silent for %v", n.ID, peer, timeout)
+	n.BlackList[peer] = true
+	n.Metrics.SilentPeers++
+}
+
 // Helper function to find the closing brace of a JSON object
 func findClosingBrace(data []byte) int {
 	openBraces := 0
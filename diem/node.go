@@ -1,8 +1,6 @@
 package main
 
 import (
-	"crypto/sha256"
-	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -35,29 +33,65 @@ func (n *Node) sendChunkRequest(blockID int) {
 		SuccessfulChunks: 0,
 		FailedChunks:     0,
 	}
+	n.Ledger = NewLocalLedger(blockID)
 
-	request := &ChunkRequest{
-		NodeID:         n.ID,
-		BlockID:        blockID,
-		TransactionIDs: []string{},
-		RequestSize:    CHUNK_SIZE,
+	switch FETCH_MODE {
+	case "parallel":
+		n.fetchRanges(blockID)
+	default:
+		n.streamBlock(blockID)
 	}
+	n.finishSync()
+	// Update and display metrics
+	n.Metrics.TotalTransactions = n.Ledger.Version()
+	n.Metrics.EndTime = time.Now()
+	n.Metrics.TotalDuration = n.Metrics.EndTime.Sub(n.Metrics.StartTime)
+	fmt.Printf("Sync Metrics for Node %d: %+v\n", n.ID, n.Metrics)
+}
 
-	message := &Message{
-		From:    n.ID,
-		To:      -1,
-		Type:    "request",
-		Content: request,
-	}
-	fmt.Println(request)
-
-	requestData, err := json.Marshal(message)
-	if err != nil {
-		log.Fatalf("Error marshaling request: %v", err)
-	}
+// streamBlock fetches the whole block from one peer. When the stream fails
+// the next peer is asked to continue from the last verified version, until
+// no peer is left.
+func (n *Node) streamBlock(blockID int) {
 	for {
+		n.lock.Lock()
+		startVersion := n.Ledger.Version()
+		complete := n.LedgerInfo != nil && uint64(startVersion) >= n.LedgerInfo.LedgerInfo.Version
+		n.lock.Unlock()
+		if complete {
+			break
+		}
+		if startVersion > 0 {
+			fmt.Printf("Node %d resuming block %d from version %d\n", n.ID, blockID, startVersion)
+			n.Metrics.Resumes++
+		}
+		request := &ChunkRequest{
+			NodeID:         n.ID,
+			BlockID:        blockID,
+			TransactionIDs: []string{},
+			RequestSize:    CHUNK_SIZE,
+			StartVersion:   startVersion,
+		}
+
+		message := &Message{
+			From:    n.ID,
+			To:      -1,
+			Type:    "request",
+			Content: request,
+		}
+		fmt.Println(request)
+
+		requestData, err := json.Marshal(message)
+		if err != nil {
+			log.Fatalf("Error marshaling request: %v", err)
+		}
 		// selectedPeer := n.Peers[rand.Intn(len(n.Peers))]
 		indexPeer := n.selectRandomPeer()
+		if indexPeer < 0 {
+			// finishSync reports the ledger as far as it got
+			log.Printf("Node %d has no peer left to stream block %d from", n.ID, blockID)
+			return
+		}
 		fmt.Println("Chosen peer: ", indexPeer)
 		selectedPeer := n.Peers[indexPeer]
 		conn, err := net.Dial("tcp", selectedPeer)
@@ -73,7 +107,8 @@ func (n *Node) sendChunkRequest(blockID int) {
 		}
 		// readDeadline := time.Now().Add(10 * time.Second)
 		// conn.SetReadDeadline(readDeadline)
-		res := n.readResponse(conn, indexPeer)
+		res := n.readResponse(conn, indexPeer, nil)
+		conn.Close()
 		if res {
 			break
 		} else {
@@ -81,30 +116,55 @@ func (n *Node) sendChunkRequest(blockID int) {
 			continue
 		}
 	}
-	// Update and display metrics
-	n.Metrics.EndTime = time.Now()
-	n.Metrics.TotalDuration = n.Metrics.EndTime.Sub(n.Metrics.StartTime)
-	fmt.Printf("Sync Metrics for Node %d: %+v\n", n.ID, n.Metrics)
 }
 
 func (n *Node) processChunkRequest(request *ChunkRequest, conn net.Conn) {
-	block := n.generateBlockForRequest(request.BlockID)
+	committed := n.Network.committedBlock(request.BlockID, n.generateBlockForRequest)
+	block := committed.Block
 	txs := block.Transactions
 	fmt.Println("num of txs: ", len(txs))
 	if n.IsByzantine {
 		fmt.Println("I am a byzantine node")
 		// DOING NOTHING
 	} else {
-		for i := 0; i < len(block.Transactions); i += request.RequestSize {
+		// The whole block from the start version, or the ranges starting at
+		// the requested transactions
+		if request.RequestSize <= 0 {
+			n.sendError(request, conn, fmt.Sprintf("request size %d is not positive", request.RequestSize))
+			return
+		}
+		if request.StartVersion < 0 || request.StartVersion >= len(txs) {
+			n.sendError(request, conn, fmt.Sprintf("start version %d outside %d transactions", request.StartVersion, len(txs)))
+			return
+		}
+		var starts []int
+		for i := request.StartVersion; i < len(txs) && len(request.TransactionIDs) == 0; i += request.RequestSize {
+			starts = append(starts, i)
+		}
+		for _, id := range request.TransactionIDs {
+			version, ok := committed.Versions[id]
+			if !ok {
+				n.sendError(request, conn, fmt.Sprintf("unknown transaction %q", id))
+				return
+			}
+			starts = append(starts, version)
+		}
+		for j, i := range starts {
 			chunk := txs[i:min(i+request.RequestSize, len(txs))]
-			proof, _ := generateProof(chunk)
+			proof, err := committed.Accumulator.RangeProof(uint64(i), uint64(len(chunk)))
+			if err != nil {
+				log.Printf("Error proving transactions from %d: %v", i, err)
+				return
+			}
 
 			// Serialize and send the response
 			response := ChunkResponse{
 				NodeID:       n.ID,
 				BlockID:      block.ID,
-				Transactions: txs[i:min(i+request.RequestSize, len(txs))],
-				Proof:        proof.LeftSiblings,
+				FirstVersion: i,
+				Transactions: chunk,
+				Proof:        proof,
+				LedgerInfo:   committed.LedgerInfo,
 				Success:      true,
 			}
 
@@ -114,7 +174,7 @@ func (n *Node) processChunkRequest(request *ChunkRequest, conn net.Conn) {
 				Type:    "response",
 				Content: response,
 			}
-			if (i + request.RequestSize) >= len(txs) {
+			if j == len(starts)-1 {
 				message.Type = "last_response"
 			}
 
@@ -129,15 +189,34 @@ func (n *Node) processChunkRequest(request *ChunkRequest, conn net.Conn) {
 				log.Printf("Error writing response to connection: %v", err)
 				return
 			}
-			time.Sleep(1 * time.Second)
+			// Time to upload the response at UPLOAD_BANDWIDTH
+			time.Sleep(time.Duration(len(responseBytes)) * time.Second / time.Duration(UPLOAD_BANDWIDTH))
 			time.Sleep(NETWORK_DELAY)
 		}
 	}
 }
 
+// sendError answers a request that cannot be served
+func (n *Node) sendError(request *ChunkRequest, conn net.Conn, errorMessage string) {
+	message := &Message{
+		From:    n.ID,
+		To:      request.NodeID,
+		Type:    "last_response",
+		Content: ChunkResponse{NodeID: n.ID, BlockID: request.BlockID, ErrorMessage: errorMessage},
+	}
+	responseBytes, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("Error marshaling response: %v", err)
+		return
+	}
+	if _, err := conn.Write(responseBytes); err != nil {
+		log.Printf("Error writing response to connection: %v", err)
+	}
+}
+
 func (n *Node) generateBlockForRequest(blockID int) *Block {
 
-	txs := GenerateTransactions(TXN_SIZE)
+	txs := BlockTransactions()
 	block := &Block{
 		ID:           blockID,
 		PreviousHash: "",
@@ -153,60 +232,78 @@ func (n *Node) generateBlockForRequest(blockID int) *Block {
 	return block
 }
 
-func generateProof(transactions []Transaction) (TransactionAccumulatorRangeProof, []TransactionInfo) {
-	var leftSiblings []string
-	var transactionInfos []TransactionInfo
-
-	for _, txn := range transactions {
-		hash := sha256.Sum256([]byte(txn.ID))
-		leftSiblings = append(leftSiblings, hex.EncodeToString(hash[:]))
-		transactionInfos = append(transactionInfos, TransactionInfo{TransactionHash: hex.EncodeToString(hash[:])})
-	}
-
-	return TransactionAccumulatorRangeProof{LeftSiblings: leftSiblings}, transactionInfos
-}
-
-func verifyProof(transactions []Transaction, proof TransactionAccumulatorRangeProof) bool {
-	for i, txn := range transactions {
-		hash := sha256.Sum256([]byte(txn.ID))
-		if proof.LeftSiblings[i] != hex.EncodeToString(hash[:]) {
-			return false
-		}
-	}
-	return true
-}
-
-func (n *Node) handleChunkResponse(response *ChunkResponse) {
+// handleChunkResponse verifies and integrates a chunk of size bytes from
+// peer and reports whether it was accepted. A peer whose chunk fails is
+// blacklisted so the caller moves on at once.
+func (n *Node) handleChunkResponse(response *ChunkResponse, peer, size int) bool {
+	n.lock.Lock()
+	defer n.lock.Unlock()
 	n.Metrics.TotalChunks++
+	n.Metrics.ReceivedBytes += size
 	if n.verifyChunk(response) {
 		n.Metrics.SuccessfulChunks++
-		n.integrateChunk(response)
+		overlap, err := n.integrateChunk(response)
+		if err != nil {
+			log.Printf("Node %d failed to integrate chunk: %v", n.ID, err)
+			n.Metrics.WastedBytes += size
+			n.BlackList[peer] = true
+			return false
+		}
+		// Transactions fetched again cost their share of the response
+		n.Metrics.WastedBytes += size * overlap / len(response.Transactions)
 		fmt.Println("Chunk integrated successfully.")
-	} else {
-		n.Metrics.FailedChunks++
-		fmt.Println("Failed to verify chunk.")
-		// TODO: Implement retry logic
-
+		return true
 	}
-
+	n.Metrics.FailedChunks++
+	n.Metrics.WastedBytes += size
+	fmt.Println("Failed to verify chunk.")
+	log.Printf("Node %d blacklisting node %d for a bad chunk", n.ID, peer)
+	n.BlackList[peer] = true
+	return false
 }
 
+// verifyChunk checks the ledger info signatures and then the range proof of
+// the transactions against its accumulator root
 func (n *Node) verifyChunk(response *ChunkResponse) bool {
 	startTime := time.Now()
-	proof := TransactionAccumulatorRangeProof{LeftSiblings: response.Proof}
-	n.Metrics.VerificationTime += time.Since(startTime)
-	return verifyProof(response.Transactions, proof)
+	defer func() { n.Metrics.VerificationTime += time.Since(startTime) }()
+	if err := n.trustLedgerInfo(response.LedgerInfo); err != nil {
+		log.Printf("Node %d rejected ledger info from node %d: %v", n.ID, response.NodeID, err)
+		return false
+	}
+	leaves := make([]HashValue, len(response.Transactions))
+	for i, txn := range response.Transactions {
+		leaves[i] = NewTransactionInfo(txn).Hash()
+	}
+	li := n.LedgerInfo.LedgerInfo
+	if err := response.Proof.Verify(li.AccumulatorRoot, li.Version, uint64(response.FirstVersion), leaves); err != nil {
+		log.Printf("Node %d rejected transactions from node %d: %v", n.ID, response.NodeID, err)
+		return false
+	}
+	return true
+}
+
+// integrateChunk appends the verified transactions to the local ledger and
+// returns how many of them it already had
+func (n *Node) integrateChunk(response *ChunkResponse) (int, error) {
+	return n.Ledger.Integrate(response.FirstVersion, response.Transactions, n.Metrics)
 }
 
-func (n *Node) integrateChunk(response *ChunkResponse) {
-	// TODO
-	// Implement integration of chunk into blockchain
+// countWaste adds bytes that were received but never made it to a verified
+// chunk
+func (n *Node) countWaste(size int) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.Metrics.ReceivedBytes += size
+	n.Metrics.WastedBytes += size
 }
 
 func (n *Node) selectRandomPeer() int {
+	n.lock.Lock()
+	defer n.lock.Unlock()
 	availablePeers := []int{}
 	for peerID := range n.Peers {
-		if !n.BlackList[peerID] && peerID != n.ID {
+		if !n.BlackList[peerID] && !n.Busy[peerID] && peerID != n.ID {
 			availablePeers = append(availablePeers, peerID)
 		}
 	}
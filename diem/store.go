@@ -0,0 +1,130 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+)
+
+// LocalLedger is the syncing node's copy of the block being synced. Verified
+// chunks are appended in version order and the accumulator grows with them;
+// a chunk that starts past the end waits until the gap before it is filled.
+type LocalLedger struct {
+	BlockID      int
+	Transactions []Transaction         // Integrated transactions, in version order
+	Accumulator  *Accumulator          // Accumulator over Transactions
+	Pending      map[int][]Transaction // Verified chunks past the end, by first version
+}
+
+func NewLocalLedger(blockID int) *LocalLedger {
+	return &LocalLedger{
+		BlockID:     blockID,
+		Accumulator: NewAccumulator(),
+		Pending:     make(map[int][]Transaction),
+	}
+}
+
+// Version is the number of integrated transactions
+func (l *LocalLedger) Version() int {
+	return len(l.Transactions)
+}
+
+// appendFrom appends the transactions of a chunk starting at first, skipping
+// those already integrated. It returns how many overlapped.
+func (l *LocalLedger) appendFrom(first int, txs []Transaction) (int, error) {
+	overlap := min(l.Version()-first, len(txs))
+	for i := 0; i < overlap; i++ {
+		if txs[i] != l.Transactions[first+i] {
+			return 0, fmt.Errorf("transaction %d differs from the integrated one", first+i)
+		}
+	}
+	for _, txn := range txs[overlap:] {
+		l.Transactions = append(l.Transactions, txn)
+		l.Accumulator.Append(NewTransactionInfo(txn).Hash())
+	}
+	return overlap, nil
+}
+
+// Integrate adds a verified chunk and any pending chunks it connects to. It
+// returns how many transactions of the chunk were already integrated.
+func (l *LocalLedger) Integrate(first int, txs []Transaction, metrics *SyncMetrics) (int, error) {
+	if first > l.Version() {
+		metrics.Gaps++
+		l.Pending[first] = txs
+		return 0, nil
+	}
+	overlap, err := l.appendFrom(first, txs)
+	if err != nil {
+		return 0, err
+	}
+	metrics.OverlappingTransactions += overlap
+
+	// Chunks waiting behind the gap may connect now
+	for len(l.Pending) > 0 {
+		starts := make([]int, 0, len(l.Pending))
+		for start := range l.Pending {
+			starts = append(starts, start)
+		}
+		sort.Ints(starts)
+		if starts[0] > l.Version() {
+			break
+		}
+		pending := l.Pending[starts[0]]
+		delete(l.Pending, starts[0])
+		pendingOverlap, err := l.appendFrom(starts[0], pending)
+		if err != nil {
+			return 0, err
+		}
+		metrics.OverlappingTransactions += pendingOverlap
+	}
+	return overlap, nil
+}
+
+// Check compares the rebuilt ledger with the signed ledger info and the
+// source block
+func (l *LocalLedger) Check(li LedgerInfo, source *Block) error {
+	if uint64(l.Version()) != li.Version {
+		return fmt.Errorf("ledger has %d of %d transactions, %d chunks pending", l.Version(), li.Version, len(l.Pending))
+	}
+	if l.Accumulator.RootHash() != li.AccumulatorRoot {
+		return errors.New("accumulator root differs from the ledger info")
+	}
+	block := Block{
+		ID:           l.BlockID,
+		Transactions: l.Transactions,
+		PreviousHash: source.PreviousHash,
+		Nonce:        source.Nonce,
+		Timestamp:    source.Timestamp,
+	}
+	if GenerateBlockHash(block) != li.BlockHash {
+		return errors.New("rebuilt block hash differs from the ledger info")
+	}
+	for i, txn := range source.Transactions {
+		if txn != l.Transactions[i] {
+			return fmt.Errorf("transaction %d differs from the source block", i)
+		}
+	}
+	return nil
+}
+
+// finishSync checks the rebuilt block and appends it to the node's chain
+func (n *Node) finishSync() {
+	if n.LedgerInfo == nil {
+		log.Printf("Node %d synced no verified chunk", n.ID)
+		return
+	}
+	n.Network.lock.Lock()
+	source := n.Network.Committed[n.Ledger.BlockID].Block
+	n.Network.lock.Unlock()
+	if err := n.Ledger.Check(n.LedgerInfo.LedgerInfo, source); err != nil {
+		log.Printf("Node %d failed to rebuild block %d: %v", n.ID, n.Ledger.BlockID, err)
+		return
+	}
+	block := *source
+	block.Transactions = n.Ledger.Transactions
+	n.Blockchain = append(n.Blockchain, &block)
+	n.BlockHeight = len(n.Blockchain)
+	fmt.Printf("Node %d rebuilt block %d: %d transactions, root %s\n",
+		n.ID, block.ID, n.Ledger.Version(), n.Ledger.Accumulator.RootHash())
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Adaptive read timeouts. The node keeps moving averages of how long peers
+// take to start a response and of the throughput while one arrives, and
+// allows a multiple of them. A peer that stays silent past that is taken for
+// Byzantine and blacklisted, one that is sending, only slowly, is left for
+// another peer without blame.
+
+const (
+	MAX_TIMEOUT    = 10 * time.Second // Allowed before anything was observed, and the cap
+	MIN_TIMEOUT    = 1 * time.Second
+	TIMEOUT_FACTOR = 4   // Multiple of the observed time a peer is allowed
+	ewmaWeight     = 0.2 // Weight of a new observation in the averages
+)
+
+type AdaptiveTimeout struct {
+	Latency    time.Duration // Average wait for the first byte of a response
+	Throughput float64       // Average bytes per second while a response arrives
+	lock       sync.Mutex
+}
+
+// observeWait adds the wait before a response started. A longer wait than
+// the average is taken at once, a shorter one only pulls it down slowly.
+func (t *AdaptiveTimeout) observeWait(wait time.Duration) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if wait > t.Latency {
+		t.Latency = wait
+	} else {
+		t.Latency += time.Duration(ewmaWeight * float64(wait-t.Latency))
+	}
+}
+
+// observeTransfer adds a response of size bytes that took transfer to arrive
+func (t *AdaptiveTimeout) observeTransfer(size int, transfer time.Duration) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if transfer <= 0 {
+		return
+	}
+	throughput := float64(size) / transfer.Seconds()
+	if t.Throughput == 0 {
+		t.Throughput = throughput
+	} else {
+		t.Throughput += ewmaWeight * (throughput - t.Throughput)
+	}
+}
+
+// idle is how long to wait for a response to start
+func (t *AdaptiveTimeout) idle() time.Duration {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.Latency == 0 {
+		return MAX_TIMEOUT
+	}
+	return clampTimeout(TIMEOUT_FACTOR * t.Latency)
+}
+
+// transfer is how long to wait for the next read of a response under way
+func (t *AdaptiveTimeout) transfer() time.Duration {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.Throughput == 0 {
+		return MAX_TIMEOUT
+	}
+	return clampTimeout(time.Duration(TIMEOUT_FACTOR * BUFFER_SIZE / t.Throughput * float64(time.Second)))
+}
+
+func clampTimeout(timeout time.Duration) time.Duration {
+	return max(MIN_TIMEOUT, min(MAX_TIMEOUT, timeout))
+}
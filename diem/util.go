@@ -3,6 +3,7 @@ package main
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"strconv"
 	"time"
 )
@@ -37,3 +38,34 @@ func GenerateBlockHash(block Block) string {
 	hasher.Write([]byte(strconv.FormatInt(block.Timestamp, 10)))
 	return hex.EncodeToString(hasher.Sum(nil))
 }
+
+func SizeOfOneTransaction() int {
+	if dataset != nil {
+		// Dataset transactions vary in size, take the average
+		return SizeOfTheFile() / TXN_SIZE
+	}
+	tx := Transaction{
+		ID:        "0",
+		Content:   "Data for transaction 0",
+		Signature: GenerateSignature("Data for transaction 0"),
+		Timestamp: time.Now().Unix(),
+	}
+	// byte size of a transaction
+	txBytes, _ := json.Marshal(tx)
+	return len(txBytes)
+}
+
+func SizeOfTheFile() int {
+	txs := BlockTransactions()
+	block := &Block{
+		ID:           1,
+		PreviousHash: "",
+		Transactions: txs,
+		Nonce:        0,
+		Timestamp:    time.Now().Unix(),
+		Hash:         "",
+	}
+	/// size of the block in byte
+	blockBytes, _ := json.Marshal(block)
+	return len(blockBytes)
+}
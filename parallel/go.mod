@@ -3,8 +3,15 @@ module parallel
 go 1.22.1
 
 require (
+	github.com/cloudflare/circl v1.6.1
+	github.com/klauspost/compress v1.18.0
 	github.com/tendermint/tendermint v0.35.9
 	golang.org/x/exp v0.0.0-20200331195152-e8c3332aa8e5
 )
 
-require github.com/gogo/protobuf v1.3.2 // indirect
+require (
+	github.com/bwesterb/go-ristretto v1.2.3 // indirect
+	github.com/gogo/protobuf v1.3.2 // indirect
+	golang.org/x/crypto v0.11.1-0.20230711161743-2e82bdd1719d // indirect
+	golang.org/x/sys v0.10.0 // indirect
+)
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	bls "github.com/cloudflare/circl/ecc/bls12381"
+)
+
+// ###################################
+// KZG polynomial commitments over BLS12-381
+//
+// The codeword of N chunks is seen as the evaluations f(i) = H(chunk_i) of a
+// polynomial of degree < N, with H mapping a chunk to a scalar. The
+// commitment is f(tau)*G1 and the opening proof for chunk i is
+// q(tau)*G1 with q(x) = (f(x) - f(i)) / (x - i). Both are one compressed
+// G1 point however large N gets.
+
+// KZGSetup holds the powers of the secret tau. A real deployment takes them
+// from a ceremony, NewKZGSetup makes a local one for testing.
+type KZGSetup struct {
+	G1Powers []bls.G1 // tau^j * G1 for j < N
+	G2Tau    bls.G2   // tau * G2
+}
+
+// NewKZGSetup generates a setup for polynomials of degree < n. tau is
+// discarded, so whoever runs this must be trusted to forget it.
+func NewKZGSetup(n int) *KZGSetup {
+	var tau bls.Scalar
+	if err := tau.Random(rand.Reader); err != nil {
+		panic(err)
+	}
+	setup := &KZGSetup{G1Powers: make([]bls.G1, n)}
+	var power bls.Scalar
+	power.SetOne()
+	for j := 0; j < n; j++ {
+		setup.G1Powers[j].ScalarMult(&power, bls.G1Generator())
+		power.Mul(&power, &tau)
+	}
+	setup.G2Tau.ScalarMult(&tau, bls.G2Generator())
+	return setup
+}
+
+// chunkScalar maps a chunk to its evaluation
+func chunkScalar(data []byte) bls.Scalar {
+	digest := sha256.Sum256(data)
+	var s bls.Scalar
+	s.SetBytes(digest[:])
+	return s
+}
+
+// interpolate returns the coefficients of the polynomial of degree < n with
+// f(i) = ys[i] for i < n, by Lagrange interpolation in O(n^2)
+func interpolate(ys []bls.Scalar) []bls.Scalar {
+	n := len(ys)
+	xs := make([]bls.Scalar, n)
+	for i := range xs {
+		xs[i].SetUint64(uint64(i))
+	}
+
+	// master(x) = prod (x - x_i), lowest coefficient first
+	master := make([]bls.Scalar, n+1)
+	master[0].SetOne()
+	for i := 0; i < n; i++ {
+		for j := i + 1; j > 0; j-- {
+			var t bls.Scalar
+			t.Mul(&master[j], &xs[i])
+			master[j].Set(&master[j-1])
+			master[j].Sub(&master[j], &t)
+		}
+		master[0].Mul(&master[0], &xs[i])
+		master[0].Neg()
+	}
+
+	coefficients := make([]bls.Scalar, n)
+	for i := 0; i < n; i++ {
+		// basis(x) = master(x) / (x - x_i), scaled by y_i / basis(x_i)
+		basis := divideLinear(master, &xs[i])
+		var denominator bls.Scalar
+		denominator.SetOne()
+		for j := 0; j < n; j++ {
+			if j != i {
+				var d bls.Scalar
+				d.Sub(&xs[i], &xs[j])
+				denominator.Mul(&denominator, &d)
+			}
+		}
+		var scale bls.Scalar
+		scale.Inv(&denominator)
+		scale.Mul(&scale, &ys[i])
+		for j := range coefficients {
+			var t bls.Scalar
+			t.Mul(&basis[j], &scale)
+			coefficients[j].Add(&coefficients[j], &t)
+		}
+	}
+	return coefficients
+}
+
+// divideLinear divides p by (x - x0) by synthetic division, dropping the
+// remainder
+func divideLinear(p []bls.Scalar, x0 *bls.Scalar) []bls.Scalar {
+	q := make([]bls.Scalar, len(p)-1)
+	var carry bls.Scalar
+	for j := len(p) - 1; j > 0; j-- {
+		var t bls.Scalar
+		t.Mul(&carry, x0)
+		carry.Add(&p[j], &t)
+		q[j-1].Set(&carry)
+	}
+	return q
+}
+
+// commitPolynomial computes p(tau)*G1 from the setup powers
+func (s *KZGSetup) commitPolynomial(p []bls.Scalar) bls.G1 {
+	var commitment, term bls.G1
+	commitment.SetIdentity()
+	for j := range p {
+		term.ScalarMult(&p[j], &s.G1Powers[j])
+		commitment.Add(&commitment, &term)
+	}
+	return commitment
+}
+
+func (s *KZGSetup) Name() string { return "kzg" }
+
+// kzgOpener keeps the committed polynomial and opens positions on demand
+type kzgOpener struct {
+	setup *KZGSetup
+	f     []bls.Scalar
+}
+
+// Commit interpolates the chunk evaluations and commits to the polynomial
+func (s *KZGSetup) Commit(data [][]byte) ([]byte, VectorOpener, error) {
+	if len(data) > len(s.G1Powers) {
+		return nil, nil, fmt.Errorf("KZG setup supports %d chunks, got %d", len(s.G1Powers), len(data))
+	}
+	ys := make([]bls.Scalar, len(data))
+	for i, chunk := range data {
+		ys[i] = chunkScalar(chunk)
+	}
+	f := interpolate(ys)
+	commitment := s.commitPolynomial(f)
+	return commitment.BytesCompressed(), &kzgOpener{setup: s, f: f}, nil
+}
+
+// Open returns the compressed witness q(tau)*G1 for the index
+func (o *kzgOpener) Open(index int) ([]byte, error) {
+	if index < 0 || index >= len(o.f) {
+		return nil, fmt.Errorf("index %d out of range", index)
+	}
+	var x bls.Scalar
+	x.SetUint64(uint64(index))
+	// f(x) - f(index) vanishes at index, so the remainder dropped here is zero
+	witness := o.setup.commitPolynomial(divideLinear(o.f, &x))
+	return witness.BytesCompressed(), nil
+}
+
+// Verify checks that data is the index-th evaluation, i.e.
+// e(C - y*G1, G2) == e(proof, tau*G2 - index*G2)
+func (s *KZGSetup) Verify(commitment []byte, index int, data []byte, proof []byte) error {
+	var C, witness bls.G1
+	if err := C.SetBytes(commitment); err != nil {
+		return err
+	}
+	if err := witness.SetBytes(proof); err != nil {
+		return err
+	}
+
+	y := chunkScalar(data)
+	var yG1 bls.G1
+	yG1.ScalarMult(&y, bls.G1Generator())
+	yG1.Neg()
+	C.Add(&C, &yG1)
+
+	var x bls.Scalar
+	x.SetUint64(uint64(index))
+	var xG2 bls.G2
+	xG2.ScalarMult(&x, bls.G2Generator())
+	xG2.Neg()
+	var divisor bls.G2
+	divisor.Add(&s.G2Tau, &xG2)
+
+	// The pairing code cannot take the identity, which only shows up for
+	// constant polynomials or forged proofs
+	if witness.IsIdentity() || C.IsIdentity() {
+		if !witness.IsIdentity() || !C.IsIdentity() {
+			return errors.New("KZG opening does not match the commitment")
+		}
+		return nil
+	}
+	check := bls.ProdPairFrac([]*bls.G1{&C, &witness}, []*bls.G2{bls.G2Generator(), &divisor}, []int{1, -1})
+	if !check.IsIdentity() {
+		return errors.New("KZG opening does not match the commitment")
+	}
+	return nil
+}
@@ -1,27 +1,30 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"log"
 	"net"
+	"sync"
 	"time"
-
-	"github.com/tendermint/tendermint/crypto/merkle"
 )
 
 // Assuming upload bandwidth is 10 Mbps - download bandwidth is 109 Mbps
 // K = N - f | f = 10% of N
 const (
-	TXN_SIZE         = 1_000_000
 	N                = 50       // size of each coded chunk is TXN_SIZE/K !!!
 	BUFFER_SIZE      = 65536    // 2^16
 	BANDWIDTH        = 12500000 // 10 Megabit per sec = 1.25 * 10^6 bytes per second
 	UPLOAD_BANDWIDTH = 1250000
 	// 8765437
 	NETWORK_DELAY = 300 * time.Millisecond
-	COUNTER       = 3 // Bandwdith / (F/N) -- F is the size of the file in bytes
 )
 
-var F = make(map[int]bool)
+var (
+	TXN_SIZE  = 1_000_000 // Transactions in a block, those of the dataset when one is loaded
+	F         = make(map[int]bool)
+	VC_SCHEME string // Vector commitment over the chunks: merkle, kzg or pedersen-list
+)
 
 type Transaction struct {
 	ID        string // Unique identifier for the transaction
@@ -52,13 +55,16 @@ type Node struct {
 	Metrics        *SyncMetrics   // Metrics for tracking synchronization performance
 	BlackList      map[int]bool   // List of nodes to ignore during synchronization
 	ReceivedChunks map[int]Chunk  // Map of received chunks indexed by their block ID
-
+	Upload         *TokenBucket   // Upload bandwidth shared by all connections
+	Download       *TokenBucket   // Download bandwidth shared by all connections
 }
 
 type Network struct {
 	Nodes    map[int]*Node         // Map of all nodes indexed by their ID for quick access
 	Latency  map[int]map[int]int   // Matrix to simulate network latency between nodes
 	Channels map[int]chan *Message // Channels for node-to-node communication, mapped by node ID
+	Blocks   map[int]*Block        // Block every peer serves, by block ID
+	lock     sync.Mutex
 }
 
 type Message struct {
@@ -83,32 +89,57 @@ type ChunkResponse struct {
 
 type Chunk struct {
 	Data  []byte
-	Proof merkle.Proof
+	Proof []byte // Serialized opening proof of the run's vector commitment
 }
 
 type SyncMetrics struct {
-	NodeID            int           // ID of the node for which metrics are being tracked
-	StartTime         time.Time     // Time when the first chunk request was sent
-	EndTime           time.Time     // Time when the last chunk was successfully verified and integrated
-	TotalTransactions int           // Total number of transactions received
-	TotalChunks       int           // Total number of chunks received
-	SuccessfulChunks  int           // Number of successfully verified chunks
-	FailedChunks      int           // Number of chunks that failed verification
-	TotalDuration     time.Duration // Total time taken for the synchronization process
-	VerificationTime  time.Duration // Time taken to verify all chunks
+	NodeID            int              // ID of the node for which metrics are being tracked
+	StartTime         time.Time        // Time when the first chunk request was sent
+	EndTime           time.Time        // Time when the last chunk was successfully verified and integrated
+	TotalTransactions int              // Total number of transactions received
+	TotalChunks       int              // Total number of chunks received
+	SuccessfulChunks  int              // Number of successfully verified chunks
+	FailedChunks      int              // Number of chunks that failed verification
+	TotalDuration     time.Duration    // Total time taken for the synchronization process
+	VerificationTime  time.Duration    // Time taken to verify all chunks
+	Sent              map[int]Traffic  // Serialized bytes sent to each peer
+	Received          map[int]Traffic  // Serialized bytes received from each peer
+	Compression       CompressionStats // Effect of compressing the block before coding
+	ShardBytes        int              // Size of a received chunk
+	trafficLock       sync.Mutex
 }
 
 func main() {
+	flag.StringVar(&VC_SCHEME, "vc", "merkle", "Vector commitment over the chunks: merkle, kzg or pedersen-list")
+	flag.StringVar(&DATASET, "dataset", "", "ETH or BTC transactions in JSON to fill the block with, generated ones when empty")
+	flag.IntVar(&BLOCK_BYTES, "block-bytes", 0, "Target size of the dataset block in bytes, 0 takes the whole dataset")
+	flag.StringVar(&COMPRESSION, "compress", "none", "Compression of the block before coding: none, gzip, snappy or zstd")
+	flag.StringVar(&ENCODING, "encoding", "binary", "Serialization of the block: binary or json")
+	flag.Parse()
+	if err := checkCompression(COMPRESSION); err != nil {
+		log.Fatal(err)
+	}
+	if err := checkEncoding(ENCODING); err != nil {
+		log.Fatal(err)
+	}
+	loadDataset()
+	var err error
+	if vectorCommitment, err = NewVectorCommitment(VC_SCHEME, N); err != nil {
+		log.Fatal(err)
+	}
 	// Size of a single transaction in bytes]
 	fmt.Printf("Size of a single transaction: %d bytes\n", SizeOfOneTransaction())
 	// Size of the entire file in bytes
 	fmt.Printf("Size of the entire file: %d bytes\n", SizeOfTheFile())
+	// Size of what is coded into chunks after compression
+	compressionStats = MeasureCompression(SerializedBlock())
+	fmt.Printf("Size of the compressed file: %d bytes (%s in %v)\n", compressionStats.CompressedBytes, COMPRESSION, compressionStats.CompressionTime)
 	// Maximum size of a chunk respected to the bandwidth
-	fmt.Printf("Maximum size of a chunk: %d txs\n", TXN_SIZE/(SizeOfTheFile()/UPLOAD_BANDWIDTH))
+	fmt.Printf("Maximum size of a chunk: %d txs\n", TXN_SIZE*UPLOAD_BANDWIDTH/SizeOfTheFile())
 	// Size of each coded chunk in bytes
-	fmt.Printf("Size of each coded chunk: %d bytes\n", SizeOfTheFile()/N)
+	fmt.Printf("Size of each coded chunk: %d bytes\n", compressionStats.CompressedBytes/(N-1))
 	// Maximum number of coded chunk respected to the bandwidth
-	fmt.Printf("Maximum number of coded chunks: %d\n", BANDWIDTH/(SizeOfTheFile()/N))
+	fmt.Printf("Maximum number of coded chunks: %d\n", BANDWIDTH*(N-1)/compressionStats.CompressedBytes)
 	// time.Sleep(10 * time.Second)
 	faultyNodes := []int{}
 	InitializeAdversary(faultyNodes)
@@ -18,7 +18,8 @@ func InitializeAdversary(faultyNode []int) {
 
 func InitializeNetwork(numNodes int, startingPort int) *Network {
 	network := &Network{
-		Nodes: make(map[int]*Node),
+		Nodes:  make(map[int]*Node),
+		Blocks: make(map[int]*Block),
 	}
 	for i := 0; i < N; i++ {
 		address := fmt.Sprintf("localhost:%d", startingPort+i)
@@ -38,9 +39,12 @@ func InitializeNetwork(numNodes int, startingPort int) *Network {
 			Listener:       listener,
 			Peers:          make(map[int]string),
 			Blockchain:     make([]*Block, 0),
+			Network:        network,
 			IsByzantine:    byzantine,
 			BlackList:      make(map[int]bool),
 			ReceivedChunks: make(map[int]Chunk),
+			Upload:         NewTokenBucket(UPLOAD_BANDWIDTH, BUFFER_SIZE),
+			Download:       NewTokenBucket(BANDWIDTH, BUFFER_SIZE),
 		}
 		network.Nodes[i] = node
 	}
@@ -102,6 +106,19 @@ func (n *Node) handleMessage(message Message, conn net.Conn) {
 	}
 }
 
+// servedBlock returns the block with the ID, generating it on first use so
+// every peer codes and commits the same bytes
+func (network *Network) servedBlock(blockID int, generate func(int) *Block) *Block {
+	network.lock.Lock()
+	defer network.lock.Unlock()
+	if block, ok := network.Blocks[blockID]; ok {
+		return block
+	}
+	block := generate(blockID)
+	network.Blocks[blockID] = block
+	return block
+}
+
 func (n *Node) readResponse(conn net.Conn, connectedPeer int) bool {
 	var buf [BUFFER_SIZE]byte
 	var accumulatedData bytes.Buffer
@@ -143,6 +160,7 @@ func (n *Node) readResponse(conn net.Conn, connectedPeer int) bool {
 					log.Printf("Node %d error unmarshaling chunk response: %v", n.ID, err)
 					continue
 				}
+				n.Metrics.recordReceived(connectedPeer, responseTraffic(&response, len(fullMessage)))
 				n.handleChunkResponse(&response)
 				break
 			}
@@ -19,7 +19,7 @@ func (n *Node) Start() {
 				log.Fatalf("Error accepting connection: %v", err)
 				continue
 			}
-			go n.handleIncomingConnection(conn)
+			go n.handleIncomingConnection(n.shape(conn))
 		}
 	}()
 }
@@ -33,14 +33,14 @@ func (n *Node) sendChunkRequest(blockID int) {
 		TotalChunks:      0,
 		SuccessfulChunks: 0,
 		FailedChunks:     0,
+		Sent:             make(map[int]Traffic),
+		Received:         make(map[int]Traffic),
+		Compression:      compressionStats,
 	}
 	wg := sync.WaitGroup{}
 
 	for i := 1; i < N; i++ {
 		wg.Add(1)
-		if (i%COUNTER == 0) && (i != 0) {
-			time.Sleep(1 * time.Second)
-		}
 		go func(i int) {
 			defer wg.Done()
 			request := &ChunkRequest{
@@ -62,7 +62,7 @@ func (n *Node) sendChunkRequest(blockID int) {
 			}
 			selectedPeer := n.Peers[i]
 			fmt.Println("Sending request to peer", i)
-			conn, err := net.Dial("tcp", selectedPeer)
+			conn, err := n.dial(selectedPeer)
 			if err != nil {
 				log.Fatalf("Error connecting to peer: %v", err)
 			}
@@ -72,6 +72,7 @@ func (n *Node) sendChunkRequest(blockID int) {
 				log.Printf("Error writing response to connection: %v", err)
 				return
 			}
+			n.Metrics.recordSent(i, requestTraffic(len(requestData)))
 			readDeadline := time.Now().Add(20 * time.Second)
 			conn.SetReadDeadline(readDeadline)
 			if !n.readResponse(conn, i) {
@@ -80,7 +81,7 @@ func (n *Node) sendChunkRequest(blockID int) {
 				conn.Close()
 				for {
 					index := n.selectRandomPeer()
-					conn, err = net.Dial("tcp", n.Peers[index])
+					conn, err = n.dial(n.Peers[index])
 					if err != nil {
 						log.Fatalf("Error connecting to peer: %v", err)
 					}
@@ -89,7 +90,8 @@ func (n *Node) sendChunkRequest(blockID int) {
 						log.Printf("Error writing response to connection: %v", err)
 						return
 					}
-					if n.readResponse(conn, i) {
+					n.Metrics.recordSent(index, requestTraffic(len(requestData)))
+					if n.readResponse(conn, index) {
 						break
 					} else {
 						continue
@@ -107,17 +109,25 @@ func (n *Node) sendChunkRequest(blockID int) {
 }
 
 func (n *Node) processChunkRequest(request *ChunkRequest, conn net.Conn) {
-	block := n.generateBlockForRequest(request.BlockID)
-	blockBytes, _ := json.Marshal(block)
+	block := n.Network.servedBlock(request.BlockID, n.generateBlockForRequest)
+	blockBytes := MarshalBlock(block)
 	fmt.Println("Size of block in bytes: ", len(blockBytes))
+	blockBytes, err := Compress(blockBytes)
+	if err != nil {
+		log.Printf("Error compressing block: %v", err)
+		return
+	}
 	chunks := GenerateDataChunks(blockBytes)
-	rootHash, proofs := CreateVectorCommitment(chunks)
+	rootHash, opener := CreateVectorCommitment(chunks)
 	if n.IsByzantine {
 		// DOINT NOTHING
 	} else {
-		for i := range chunks {
-			chunks[i].Proof = *proofs[i]
+		proof, err := opener.Open(request.ChunkID)
+		if err != nil {
+			log.Printf("Error opening chunk %d: %v", request.ChunkID, err)
+			return
 		}
+		chunks[request.ChunkID].Proof = proof
 		// fmt.Println("Sending chunk id", request.ChunkID, "to node", request.NodeID)
 		response := ChunkResponse{
 			NodeID:     n.ID,
@@ -140,10 +150,7 @@ func (n *Node) processChunkRequest(request *ChunkRequest, conn net.Conn) {
 		}
 		fmt.Println("Sending response to node", request.NodeID)
 		time.Sleep(NETWORK_DELAY)
-		// Upload bandwidth limitation:
-		bandwidthLimit := len(responseBytes) / UPLOAD_BANDWIDTH
-		fmt.Println("Bandwidth limit: ", bandwidthLimit)
-		time.Sleep(time.Duration(bandwidthLimit) * time.Second)
+		// Upload bandwidth is limited by the node's token bucket
 		_, err = conn.Write(responseBytes)
 		if err != nil {
 			log.Printf("Error writing response to connection: %v", err)
@@ -155,7 +162,7 @@ func (n *Node) processChunkRequest(request *ChunkRequest, conn net.Conn) {
 
 func (n *Node) generateBlockForRequest(blockID int) *Block {
 
-	txs := GenerateTransactions(TXN_SIZE)
+	txs := BlockTransactions()
 	block := &Block{
 		ID:           blockID,
 		PreviousHash: "",
@@ -176,7 +183,7 @@ func (n *Node) handleChunkResponse(response *ChunkResponse) {
 
 	n.Metrics.TotalChunks++
 	// fmt.Println("response commtiment: ", response.Commitment, "proof", response.Chunk.Proof)
-	if VerifyChunk(response.Commitment, *response.Chunk, &response.Chunk.Proof, n) {
+	if VerifyChunk(response.Commitment, *response.Chunk, response.ChunkID, n) {
 		n.Metrics.SuccessfulChunks++
 		n.ReceivedChunks[response.ChunkID] = *response.Chunk
 		fmt.Println("Chunk integrated successfully.")
@@ -186,15 +193,34 @@ func (n *Node) handleChunkResponse(response *ChunkResponse) {
 		fmt.Println("Failed to verify chunk.")
 	}
 	if len(n.ReceivedChunks) == (N - 1) {
-		fmt.Println("Enough chunks recieved, size of chunk is", len(n.ReceivedChunks[0].Data))
+		fmt.Println("Enough chunks recieved, size of chunk is", len(n.ReceivedChunks[1].Data))
 		decodedMessage, err := Decode(n.ReceivedChunks)
 		if err != nil {
 			log.Fatalf("Error decoding message: %v", err)
 		}
-		fmt.Println("Decoded message:", len(decodedMessage))
+		for _, chunk := range n.ReceivedChunks {
+			n.Metrics.ShardBytes = len(chunk.Data)
+			break
+		}
+		startTime := time.Now()
+		blockBytes, err := Decompress([]byte(decodedMessage))
+		if err != nil {
+			log.Fatalf("Error decompressing message: %v", err)
+		}
+		n.Metrics.Compression.DecompressionTime = time.Since(startTime)
+		block, err := UnmarshalBlock(blockBytes)
+		if err != nil {
+			log.Fatalf("Error unmarshaling block: %v", err)
+		}
+		if GenerateBlockHash(*block) != block.Hash {
+			log.Fatalf("Decoded block %d does not match its hash", block.ID)
+		}
+		n.Metrics.TotalTransactions = len(block.Transactions)
+		fmt.Println("Decoded message:", len(blockBytes))
 		n.Metrics.EndTime = time.Now()
 		n.Metrics.TotalDuration = n.Metrics.EndTime.Sub(n.Metrics.StartTime)
 		fmt.Printf("Sync Metrics for Node %d: %+v\n", n.ID, n.Metrics)
+		n.Metrics.PrintTraffic()
 		panic("Sync complete")
 		// size of message in byte
 	}
@@ -3,27 +3,30 @@ package main
 import (
 	"bytes"
 	"fmt"
-
-	"github.com/tendermint/tendermint/crypto/merkle"
 )
 
+// GenerateDataChunks splits the data over chunks 1 to N-1. Chunk 0 belongs
+// to the syncing node, which fetches every other one, so it stays empty.
 func GenerateDataChunks(data []byte) []Chunk {
-	chunkSize := (len(data) + N - 1) / N
+	chunkSize := (len(data) + N - 2) / (N - 1)
 	chunks := make([]Chunk, N)
 
-	for i := 0; i < N; i++ {
-		start := i * chunkSize
+	for i := 1; i < N; i++ {
+		start := (i - 1) * chunkSize
 		end := start + chunkSize
 		if end > len(data) {
 			end = len(data)
 		}
-		chunks[i] = Chunk{Data: data[start:end], Proof: merkle.Proof{}}
+		if start > end {
+			start = end
+		}
+		chunks[i] = Chunk{Data: data[start:end]}
 	}
 
 	return chunks
 }
 
-// Decode simply reassembles the chunks into the original data
+// Decode reassembles chunks 1 to N-1 into the original data
 func Decode(chunks map[int]Chunk) (string, error) {
 	var buf bytes.Buffer
 
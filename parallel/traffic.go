@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sort"
+)
+
+// ###################################
+// Bandwidth accounting
+//
+// Messages go over the wire as JSON, so every []byte field travels base64
+// encoded. Traffic splits the serialized size of a message into the bytes
+// of those fields and the framing around them: keys, IDs, quotes, braces.
+
+// Traffic is the serialized size of messages broken down by content
+type Traffic struct {
+	Payload    int // Chunk data
+	Proof      int // Opening proofs
+	Commitment int // Vector commitments
+	Framing    int // Everything else
+}
+
+func (t Traffic) Total() int {
+	return t.Payload + t.Proof + t.Commitment + t.Framing
+}
+
+func (t Traffic) Add(o Traffic) Traffic {
+	return Traffic{
+		Payload:    t.Payload + o.Payload,
+		Proof:      t.Proof + o.Proof,
+		Commitment: t.Commitment + o.Commitment,
+		Framing:    t.Framing + o.Framing,
+	}
+}
+
+// wireLen is the size of b inside its JSON string
+func wireLen(b []byte) int {
+	return base64.StdEncoding.EncodedLen(len(b))
+}
+
+// requestTraffic accounts a serialized request, which is all framing
+func requestTraffic(size int) Traffic {
+	return Traffic{Framing: size}
+}
+
+// responseTraffic accounts a serialized response of size bytes
+func responseTraffic(response *ChunkResponse, size int) Traffic {
+	t := Traffic{Commitment: wireLen(response.Commitment)}
+	if response.Chunk != nil {
+		t.Payload = wireLen(response.Chunk.Data)
+		t.Proof = wireLen(response.Chunk.Proof)
+	}
+	t.Framing = size - t.Payload - t.Proof - t.Commitment
+	return t
+}
+
+func (m *SyncMetrics) recordSent(peer int, t Traffic) {
+	m.trafficLock.Lock()
+	defer m.trafficLock.Unlock()
+	m.Sent[peer] = m.Sent[peer].Add(t)
+}
+
+func (m *SyncMetrics) recordReceived(peer int, t Traffic) {
+	m.trafficLock.Lock()
+	defer m.trafficLock.Unlock()
+	m.Received[peer] = m.Received[peer].Add(t)
+}
+
+// PrintTraffic prints the bytes exchanged with every peer and the totals
+func (m *SyncMetrics) PrintTraffic() {
+	m.trafficLock.Lock()
+	defer m.trafficLock.Unlock()
+	peers := []int{}
+	for peer := range m.Sent {
+		peers = append(peers, peer)
+	}
+	for peer := range m.Received {
+		if _, ok := m.Sent[peer]; !ok {
+			peers = append(peers, peer)
+		}
+	}
+	sort.Ints(peers)
+
+	fmt.Println("peer,direction,payload,proof,commitment,framing,total")
+	var sent, received Traffic
+	for _, peer := range peers {
+		for _, row := range []struct {
+			direction string
+			t         Traffic
+		}{{"sent", m.Sent[peer]}, {"received", m.Received[peer]}} {
+			if row.t.Total() > 0 {
+				fmt.Printf("%d,%s,%d,%d,%d,%d,%d\n", peer, row.direction,
+					row.t.Payload, row.t.Proof, row.t.Commitment, row.t.Framing, row.t.Total())
+			}
+		}
+		sent = sent.Add(m.Sent[peer])
+		received = received.Add(m.Received[peer])
+	}
+	fmt.Printf("all,sent,%d,%d,%d,%d,%d\n", sent.Payload, sent.Proof, sent.Commitment, sent.Framing, sent.Total())
+	fmt.Printf("all,received,%d,%d,%d,%d,%d\n", received.Payload, received.Proof, received.Commitment, received.Framing, received.Total())
+}
@@ -29,6 +29,12 @@ func GenerateSignature(data string) string {
 }
 
 func GenerateBlockHash(block Block) string {
+	if ENCODING == "binary" {
+		// The hash covers the binary form of everything but itself
+		block.Hash = ""
+		sum := sha256.Sum256(EncodeBlock(&block))
+		return hex.EncodeToString(sum[:])
+	}
 	hasher := sha256.New()
 	hasher.Write([]byte(block.PreviousHash))
 	for _, txn := range block.Transactions {
@@ -40,6 +46,10 @@ func GenerateBlockHash(block Block) string {
 }
 
 func SizeOfOneTransaction() int {
+	if dataset != nil {
+		// Dataset transactions vary in size, take the average
+		return SizeOfTheFile() / TXN_SIZE
+	}
 	tx := Transaction{
 		ID:        "0",
 		Content:   "Data for transaction 0",
@@ -47,12 +57,21 @@ func SizeOfOneTransaction() int {
 		Timestamp: time.Now().Unix(),
 	}
 	// byte size of a transaction
+	if ENCODING == "binary" {
+		return len(EncodeTransaction(tx))
+	}
 	txBytes, _ := json.Marshal(tx)
 	return len(txBytes)
 }
 
 func SizeOfTheFile() int {
-	txs := GenerateTransactions(TXN_SIZE)
+	return len(SerializedBlock())
+}
+
+// SerializedBlock is the block as it is coded into chunks, before any
+// compression
+func SerializedBlock() []byte {
+	txs := BlockTransactions()
 	block := &Block{
 		ID:           1,
 		PreviousHash: "",
@@ -61,8 +80,5 @@ func SizeOfTheFile() int {
 		Timestamp:    time.Now().Unix(),
 		Hash:         "",
 	}
-	/// size of the block in byte
-	blockBytes, _ := json.Marshal(block)
-	block.Hash = GenerateBlockHash(*block)
-	return len(blockBytes)
+	return MarshalBlock(block)
 }
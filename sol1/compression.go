@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// ###################################
+// Block compression
+//
+// The serialized block can be compressed before it is coded into chunks and
+// is decompressed after decoding.
+// ###################################
+
+var (
+	COMPRESSION      string           // Compression before coding: none, gzip, snappy or zstd
+	compressionStats CompressionStats // Measured on the block at startup
+)
+
+// CompressionStats tells what compressing the block did
+type CompressionStats struct {
+	Algorithm         string
+	BlockBytes        int           // Serialized block
+	CompressedBytes   int           // What is coded into chunks
+	CompressionTime   time.Duration // CPU time to compress the block once
+	DecompressionTime time.Duration // CPU time to decompress it after decoding
+}
+
+func checkCompression(algorithm string) error {
+	switch algorithm {
+	case "none", "gzip", "snappy", "zstd":
+		return nil
+	}
+	return fmt.Errorf("unknown compression %q, want none, gzip, snappy or zstd", algorithm)
+}
+
+// Compress compresses the data with COMPRESSION
+func Compress(data []byte) ([]byte, error) {
+	if COMPRESSION == "none" {
+		return data, nil
+	}
+	switch COMPRESSION {
+	case "gzip":
+		var buf bytes.Buffer
+		writer := gzip.NewWriter(&buf)
+		if _, err := writer.Write(data); err != nil {
+			return nil, err
+		}
+		if err := writer.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case "snappy":
+		return snappy.Encode(nil, data), nil
+	case "zstd":
+		encoder, err := zstd.NewWriter(nil)
+		if err != nil {
+			return nil, err
+		}
+		defer encoder.Close()
+		return encoder.EncodeAll(data, nil), nil
+	}
+	return nil, checkCompression(COMPRESSION)
+}
+
+// Decompress undoes Compress on decoded data
+func Decompress(data []byte) ([]byte, error) {
+	if COMPRESSION == "none" {
+		return data, nil
+	}
+	switch COMPRESSION {
+	case "gzip":
+		reader, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+		return io.ReadAll(reader)
+	case "snappy":
+		return snappy.Decode(nil, data)
+	case "zstd":
+		decoder, err := zstd.NewReader(nil)
+		if err != nil {
+			return nil, err
+		}
+		defer decoder.Close()
+		return decoder.DecodeAll(data, nil)
+	}
+	return nil, checkCompression(COMPRESSION)
+}
+
+// MeasureCompression compresses the serialized block once to report the
+// effect of COMPRESSION
+func MeasureCompression(blockBytes []byte) CompressionStats {
+	stats := CompressionStats{Algorithm: COMPRESSION, BlockBytes: len(blockBytes)}
+	start := time.Now()
+	compressed, err := Compress(blockBytes)
+	if err != nil {
+		panic(err)
+	}
+	stats.CompressionTime = time.Since(start)
+	stats.CompressedBytes = len(compressed)
+	return stats
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+// ###################################
+// Transaction datasets
+//
+// Blocks filled with real transactions instead of generated strings. A
+// dataset is a JSON array of ETH or BTC transactions as written by
+// archive/transactionGenerator, or a stream of them one after another.
+// Every transaction keeps its compacted JSON as Content.
+// ###################################
+
+var (
+	DATASET     string // Dataset the block is cut from, empty to generate transactions
+	BLOCK_BYTES int    // Target size of a dataset block in bytes, 0 takes the whole dataset
+	dataset     *Block // Block cut from the dataset
+)
+
+type DatasetReader struct {
+	decoder *json.Decoder
+	array   bool         // Whether the transactions sit in a JSON array
+	pending *Transaction // Transaction that did not fit in the last block
+}
+
+func NewDatasetReader(r io.Reader) (*DatasetReader, error) {
+	reader := bufio.NewReader(r)
+	// Skip to the first value to tell an array from a stream
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("empty dataset: %w", err)
+		}
+		if b == ' ' || b == '\t' || b == '\n' || b == '\r' {
+			continue
+		}
+		reader.UnreadByte()
+		d := &DatasetReader{decoder: json.NewDecoder(reader), array: b == '['}
+		if d.array {
+			if _, err := d.decoder.Token(); err != nil {
+				return nil, err
+			}
+		}
+		return d, nil
+	}
+}
+
+// next reads the transaction with the given index in its block, io.EOF at
+// the end of the dataset
+func (d *DatasetReader) next(index int, timestamp int64) (Transaction, error) {
+	if !d.decoder.More() {
+		return Transaction{}, io.EOF
+	}
+	var raw json.RawMessage
+	if err := d.decoder.Decode(&raw); err != nil {
+		return Transaction{}, err
+	}
+	var content bytes.Buffer
+	if err := json.Compact(&content, raw); err != nil {
+		return Transaction{}, err
+	}
+	return Transaction{
+		ID:        strconv.Itoa(index),
+		Content:   content.String(),
+		Signature: GenerateSignature(content.String()),
+		Timestamp: timestamp,
+	}, nil
+}
+
+// NextBlock cuts the next block from the dataset, adding transactions while
+// its MarshalBlock form stays within targetSize bytes. A block holds at least one
+// transaction and a targetSize of 0 takes all that are left.
+func (d *DatasetReader) NextBlock(id, targetSize int) (*Block, error) {
+	block := &Block{ID: id, Timestamp: time.Now().Unix()}
+	size := emptyBlockSize(block)
+	for {
+		txn := d.pending
+		d.pending = nil
+		if txn != nil {
+			txn.ID = strconv.Itoa(len(block.Transactions))
+			txn.Timestamp = block.Timestamp
+		} else {
+			next, err := d.next(len(block.Transactions), block.Timestamp)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			txn = &next
+		}
+		txnSize := transactionSize(txn, len(block.Transactions))
+		if targetSize > 0 && len(block.Transactions) > 0 && size+txnSize > targetSize {
+			d.pending = txn
+			break
+		}
+		block.Transactions = append(block.Transactions, *txn)
+		size += txnSize
+	}
+	if len(block.Transactions) == 0 {
+		return nil, io.EOF
+	}
+	block.Hash = GenerateBlockHash(*block)
+	return block, nil
+}
+
+// emptyBlockSize is the serialized size of the block before any
+// transaction, with its hash filled in
+func emptyBlockSize(block *Block) int {
+	if ENCODING == "binary" {
+		empty := *block
+		empty.Hash = GenerateBlockHash(*block)
+		return len(EncodeBlock(&empty))
+	}
+	// The hash fills "" and the transactions replace null with [...], less
+	// the comma the first one goes without
+	empty, _ := json.Marshal(block)
+	return len(empty) + len(GenerateBlockHash(*block)) - len("null") + len("[]") - 1
+}
+
+// transactionSize is what the transaction adds to a serialized block that
+// already holds count transactions
+func transactionSize(txn *Transaction, count int) int {
+	if ENCODING == "binary" {
+		// The transaction count may grow by a byte
+		grow := len(binary.AppendUvarint(nil, uint64(count+1))) - len(binary.AppendUvarint(nil, uint64(count)))
+		return len(EncodeTransaction(*txn)) + grow
+	}
+	txnBytes, _ := json.Marshal(txn)
+	return len(txnBytes) + 1 // the separating comma
+}
+
+// LoadBlocks cuts up to count blocks of targetSize bytes from the dataset
+// file
+func LoadBlocks(path string, targetSize, count int) ([]*Block, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	reader, err := NewDatasetReader(file)
+	if err != nil {
+		return nil, err
+	}
+	var blocks []*Block
+	for len(blocks) < count {
+		block, err := reader.NextBlock(len(blocks), targetSize)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, block)
+	}
+	if len(blocks) == 0 {
+		return nil, fmt.Errorf("no transactions in %s", path)
+	}
+	return blocks, nil
+}
+
+// loadDataset reads the block of the -dataset flag, if any, and sizes
+// TXN_SIZE after it
+func loadDataset() {
+	if DATASET == "" {
+		return
+	}
+	blocks, err := LoadBlocks(DATASET, BLOCK_BYTES, 1)
+	if err != nil {
+		log.Fatalf("Error loading dataset: %v", err)
+	}
+	dataset = blocks[0]
+	TXN_SIZE = len(dataset.Transactions)
+	fmt.Printf("Loaded %d transactions from %s\n", TXN_SIZE, DATASET)
+}
+
+// BlockTransactions returns the transactions every block is made of
+func BlockTransactions() []Transaction {
+	if dataset != nil {
+		return dataset.Transactions
+	}
+	return GenerateTransactions(TXN_SIZE)
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// ###################################
+// Block serialization
+//
+// Blocks go into the coder as JSON or in a compact binary form. The binary
+// form is canonical, every block has exactly one encoding:
+//
+//	block       version byte, then ID, Hash, PreviousHash, Nonce, Timestamp
+//	            and the transactions as a uvarint count followed by each
+//	            of them
+//	transaction ID, Content, Signature, Timestamp
+//
+// Integers are zigzag varints. Hash, PreviousHash and Signature are a tag
+// byte and then either the 32 bytes of a sha256 hex digest or a string;
+// an ID is a tag byte and either a decimal number as a uvarint or a string.
+// A string is its uvarint length and its bytes, and only takes the string
+// form when the compact one does not fit.
+// ###################################
+
+var ENCODING string // Serialization of the block: json or binary
+
+const (
+	blockEncodingVersion = 1
+	tagString            = 0
+	tagCompact           = 1
+)
+
+func checkEncoding(encoding string) error {
+	if encoding != "json" && encoding != "binary" {
+		return fmt.Errorf("unknown encoding %q, want json or binary", encoding)
+	}
+	return nil
+}
+
+// MarshalBlock serializes the block with ENCODING
+func MarshalBlock(block *Block) []byte {
+	if ENCODING == "binary" {
+		return EncodeBlock(block)
+	}
+	blockBytes, _ := json.Marshal(block)
+	return blockBytes
+}
+
+// UnmarshalBlock undoes MarshalBlock
+func UnmarshalBlock(data []byte) (*Block, error) {
+	if ENCODING == "binary" {
+		return DecodeBlock(data)
+	}
+	var block Block
+	if err := json.Unmarshal(data, &block); err != nil {
+		return nil, err
+	}
+	return &block, nil
+}
+
+// digest returns the bytes of s when it is a lowercase sha256 hex digest
+func digest(s string) ([]byte, bool) {
+	if len(s) != 64 {
+		return nil, false
+	}
+	raw, err := hex.DecodeString(s)
+	if err != nil || hex.EncodeToString(raw) != s {
+		return nil, false
+	}
+	return raw, true
+}
+
+// number returns the value of s when it is a decimal number without
+// leading zeros
+func number(s string) (uint64, bool) {
+	value, err := strconv.ParseUint(s, 10, 64)
+	if err != nil || strconv.FormatUint(value, 10) != s {
+		return 0, false
+	}
+	return value, true
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+func appendDigest(buf []byte, s string) []byte {
+	if raw, ok := digest(s); ok {
+		return append(append(buf, tagCompact), raw...)
+	}
+	return appendString(append(buf, tagString), s)
+}
+
+func appendID(buf []byte, s string) []byte {
+	if value, ok := number(s); ok {
+		return binary.AppendUvarint(append(buf, tagCompact), value)
+	}
+	return appendString(append(buf, tagString), s)
+}
+
+func appendTransaction(buf []byte, txn Transaction) []byte {
+	buf = appendID(buf, txn.ID)
+	buf = appendString(buf, txn.Content)
+	buf = appendDigest(buf, txn.Signature)
+	return binary.AppendVarint(buf, txn.Timestamp)
+}
+
+// EncodeTransaction is the binary form of a transaction
+func EncodeTransaction(txn Transaction) []byte {
+	return appendTransaction(nil, txn)
+}
+
+// EncodeBlock is the binary form of a block
+func EncodeBlock(block *Block) []byte {
+	buf := []byte{blockEncodingVersion}
+	buf = binary.AppendVarint(buf, int64(block.ID))
+	buf = appendDigest(buf, block.Hash)
+	buf = appendDigest(buf, block.PreviousHash)
+	buf = binary.AppendVarint(buf, int64(block.Nonce))
+	buf = binary.AppendVarint(buf, block.Timestamp)
+	buf = binary.AppendUvarint(buf, uint64(len(block.Transactions)))
+	for _, txn := range block.Transactions {
+		buf = appendTransaction(buf, txn)
+	}
+	return buf
+}
+
+// decoder reads the binary form, keeping the first error
+type decoder struct {
+	data []byte
+	err  error
+}
+
+func (d *decoder) fail(err error) {
+	if d.err == nil {
+		d.err = err
+	}
+}
+
+func (d *decoder) byte() byte {
+	if d.err != nil || len(d.data) == 0 {
+		d.fail(errors.New("block encoding ends early"))
+		return 0
+	}
+	b := d.data[0]
+	d.data = d.data[1:]
+	return b
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	value, n := binary.Uvarint(d.data)
+	if n <= 0 {
+		d.fail(errors.New("bad uvarint in block encoding"))
+		return 0
+	}
+	// Varints are canonical only in their shortest form
+	if n != len(binary.AppendUvarint(nil, value)) {
+		d.fail(errors.New("overlong uvarint in block encoding"))
+	}
+	d.data = d.data[n:]
+	return value
+}
+
+func (d *decoder) varint() int64 {
+	value := d.uvarint()
+	// Zigzag as in binary.AppendVarint
+	return int64(value>>1) ^ -int64(value&1)
+}
+
+func (d *decoder) bytes(n uint64) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n > uint64(len(d.data)) {
+		d.fail(errors.New("block encoding ends early"))
+		return nil
+	}
+	b := d.data[:n]
+	d.data = d.data[n:]
+	return b
+}
+
+func (d *decoder) string() string {
+	return string(d.bytes(d.uvarint()))
+}
+
+func (d *decoder) digest() string {
+	switch d.byte() {
+	case tagCompact:
+		return hex.EncodeToString(d.bytes(32))
+	case tagString:
+		s := d.string()
+		if _, ok := digest(s); ok {
+			d.fail(errors.New("digest stored as a string"))
+		}
+		return s
+	}
+	d.fail(errors.New("bad digest tag in block encoding"))
+	return ""
+}
+
+func (d *decoder) id() string {
+	switch d.byte() {
+	case tagCompact:
+		return strconv.FormatUint(d.uvarint(), 10)
+	case tagString:
+		s := d.string()
+		if _, ok := number(s); ok {
+			d.fail(errors.New("number stored as a string"))
+		}
+		return s
+	}
+	d.fail(errors.New("bad ID tag in block encoding"))
+	return ""
+}
+
+// DecodeBlock reads the binary form of a block
+func DecodeBlock(data []byte) (*Block, error) {
+	d := &decoder{data: data}
+	if version := d.byte(); d.err == nil && version != blockEncodingVersion {
+		return nil, fmt.Errorf("block encoding version %d", version)
+	}
+	block := &Block{
+		ID:           int(d.varint()),
+		Hash:         d.digest(),
+		PreviousHash: d.digest(),
+		Nonce:        int(d.varint()),
+		Timestamp:    d.varint(),
+	}
+	count := d.uvarint()
+	// Every transaction takes at least 5 bytes
+	if count > uint64(len(d.data))/5 {
+		d.fail(fmt.Errorf("block encoding claims %d transactions", count))
+	}
+	for i := uint64(0); i < count && d.err == nil; i++ {
+		block.Transactions = append(block.Transactions, Transaction{
+			ID:        d.id(),
+			Content:   d.string(),
+			Signature: d.digest(),
+			Timestamp: d.varint(),
+		})
+	}
+	if d.err == nil && len(d.data) != 0 {
+		d.fail(errors.New("bytes left after the block encoding"))
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	return block, nil
+}
@@ -3,17 +3,16 @@ package main
 import (
 	"flag"
 	"fmt"
+	"log"
 	"math/rand"
 	"net"
+	"sync"
 	"time"
-
-	"github.com/tendermint/tendermint/crypto/merkle"
 )
 
 // Assuming upload bandwidth is 10 Mbps - download bandwidth is 109 Mbps
 // K = N - f | f = 10% of N
 const (
-	TXN_SIZE         = 1_000_000
 	BUFFER_SIZE      = 65536    // 2^16
 	BANDWIDTH        = 12500000 // 10 Megabit per sec = 1.25 * 10^6 bytes per second
 	UPLOAD_BANDWIDTH = 1250000
@@ -21,12 +20,14 @@ const (
 )
 
 var (
-	N                int // Number of nodes (to be set through flag)
-	K                int // Number of honest nodes (to be set through flag)
+	TXN_SIZE         = 1_000_000 // Transactions in a block, those of the dataset when one is loaded
+	N                int         // Number of nodes (to be set through flag)
+	K                int         // Number of honest nodes (to be set through flag)
 	faultyNodesCount int
-	COUNTER          int
 	F                = make(map[int]bool)
 	Nodes            int
+	VC_SCHEME        string // Vector commitment over the coded chunks: merkle, kzg or pedersen-list
+	SHARDS_PER_PEER  int    // Coded chunks requested from each peer
 )
 
 type Transaction struct {
@@ -46,25 +47,28 @@ type Block struct {
 }
 
 type Node struct {
-	ID                int            // Unique identifier for the node
-	Address           string         // TCP address for the node
-	Listener          net.Listener   // Listener for incoming connections
-	Blockchain        []*Block       // Dynamic array of blocks representing the node's current blockchain
-	Network           *Network       // Reference to the network for communications
-	IsByzantine       bool           // Indicates whether the node exhibits Byzantine behavior
-	Peers             map[int]string // Map of peer nodes for direct referencing and messaging
-	BlockHeight       int            // Current height of the blockchain this node maintains
-	ConsensusRole     string         // Role of the node in the consensus process, e.g., proposer, validator
-	Metrics           *SyncMetrics   // Metrics for tracking synchronization performance
-	BlackList         map[int]bool   // List of nodes to ignore during synchronization
-	ReceivedChunks    map[int]Chunk  // Map of received chunks indexed by their block ID
-	BandwidthOverhead int
+	ID             int            // Unique identifier for the node
+	Address        string         // TCP address for the node
+	Listener       net.Listener   // Listener for incoming connections
+	Blockchain     []*Block       // Dynamic array of blocks representing the node's current blockchain
+	Network        *Network       // Reference to the network for communications
+	IsByzantine    bool           // Indicates whether the node exhibits Byzantine behavior
+	Peers          map[int]string // Map of peer nodes for direct referencing and messaging
+	BlockHeight    int            // Current height of the blockchain this node maintains
+	ConsensusRole  string         // Role of the node in the consensus process, e.g., proposer, validator
+	Metrics        *SyncMetrics   // Metrics for tracking synchronization performance
+	BlackList      map[int]bool   // List of nodes to ignore during synchronization
+	ReceivedChunks map[int]Chunk  // Map of received chunks indexed by their block ID
+	Upload         *TokenBucket   // Upload bandwidth shared by all connections
+	Download       *TokenBucket   // Download bandwidth shared by all connections
 }
 
 type Network struct {
 	Nodes    map[int]*Node         // Map of all nodes indexed by their ID for quick access
 	Latency  map[int]map[int]int   // Matrix to simulate network latency between nodes
 	Channels map[int]chan *Message // Channels for node-to-node communication, mapped by node ID
+	Blocks   map[int]*Block        // Block every peer serves, by block ID
+	lock     sync.Mutex
 }
 
 type Message struct {
@@ -75,31 +79,39 @@ type Message struct {
 }
 
 type ChunkRequest struct {
-	NodeID  int // ID of the requesting node
-	BlockID int // Identifier of the block from which data is needed
+	NodeID   int   // ID of the requesting node
+	BlockID  int   // Identifier of the block from which data is needed
+	ChunkIDs []int // Coded chunks wanted from the peer
 }
 
 type ChunkResponse struct {
-	NodeID     int    // ID of the responding node
-	Chunk      *Chunk // Data chunk with proof
-	Commitment []byte // Vector commitment for the chunks
+	NodeID     int     // ID of the responding node
+	ChunkIDs   []int   // Indices of the chunks, in the order of Chunks
+	Chunks     []Chunk // Data chunks, a single chunk carries its own proof
+	Proof      []byte  // One opening of all the chunks when there are several
+	Commitment []byte  // Vector commitment for the chunks
 }
 
 type Chunk struct {
 	Data  []byte
-	Proof merkle.Proof
+	Proof []byte // Serialized opening proof of the run's vector commitment
 }
 
 type SyncMetrics struct {
-	NodeID            int           // ID of the node for which metrics are being tracked
-	StartTime         time.Time     // Time when the first chunk request was sent
-	EndTime           time.Time     // Time when the last chunk was successfully verified and integrated
-	TotalTransactions int           // Total number of transactions received
-	TotalChunks       int           // Total number of chunks received
-	SuccessfulChunks  int           // Number of successfully verified chunks
-	FailedChunks      int           // Number of chunks that failed verification
-	TotalDuration     time.Duration // Total time taken for the synchronization process
-	VerificationTime  time.Duration // Time taken to verify all chunks
+	NodeID            int              // ID of the node for which metrics are being tracked
+	StartTime         time.Time        // Time when the first chunk request was sent
+	EndTime           time.Time        // Time when the last chunk was successfully verified and integrated
+	TotalTransactions int              // Total number of transactions received
+	TotalChunks       int              // Total number of chunks received
+	SuccessfulChunks  int              // Number of successfully verified chunks
+	FailedChunks      int              // Number of chunks that failed verification
+	TotalDuration     time.Duration    // Total time taken for the synchronization process
+	VerificationTime  time.Duration    // Time taken to verify all chunks
+	Sent              map[int]Traffic  // Serialized bytes sent to each peer
+	Received          map[int]Traffic  // Serialized bytes received from each peer
+	Compression       CompressionStats // Effect of compressing the block before coding
+	ShardBytes        int              // Size of a received chunk
+	trafficLock       sync.Mutex
 }
 
 // Generate faulty nodes - output an array of faulty nodes which are randomly selected
@@ -116,23 +128,44 @@ func faultyNodesDriver(count int) []int {
 func main() {
 	flag.IntVar(&N, "N", 50, "Number of nodes")
 	flag.IntVar(&faultyNodesCount, "f", 15, "Number of faulty nodes")
+	flag.StringVar(&VC_SCHEME, "vc", "merkle", "Vector commitment over the coded chunks: merkle, kzg or pedersen-list")
+	flag.IntVar(&SHARDS_PER_PEER, "shards", 1, "Coded chunks requested from each peer, proven together with one multi-proof")
+	flag.StringVar(&DATASET, "dataset", "", "ETH or BTC transactions in JSON to fill the block with, generated ones when empty")
+	flag.IntVar(&BLOCK_BYTES, "block-bytes", 0, "Target size of the dataset block in bytes, 0 takes the whole dataset")
+	flag.StringVar(&COMPRESSION, "compress", "none", "Compression of the block before coding: none, gzip, snappy or zstd")
+	flag.StringVar(&ENCODING, "encoding", "binary", "Serialization of the block: binary or json")
 	flag.Parse()
+	if err := checkCompression(COMPRESSION); err != nil {
+		log.Fatal(err)
+	}
+	if err := checkEncoding(ENCODING); err != nil {
+		log.Fatal(err)
+	}
+	loadDataset()
 	fmt.Println("F:", faultyNodesCount)
 	K = N - faultyNodesCount
 	N++
 	Nodes = N
+	if SHARDS_PER_PEER < 1 || SHARDS_PER_PEER > N {
+		log.Fatalf("shards must be between 1 and %d", N)
+	}
+	var err error
+	if vectorCommitment, err = NewVectorCommitment(VC_SCHEME, N); err != nil {
+		log.Fatal(err)
+	}
 	// Size of a single transaction in bytes]
 	fmt.Printf("Size of a single transaction: %d bytes\n", SizeOfOneTransaction())
 	// Size of the entire file in bytes
 	fmt.Printf("Size of the entire file: %d bytes\n", SizeOfTheFile())
+	// Size of what is coded into chunks after compression
+	compressionStats = MeasureCompression(SerializedBlock())
+	fmt.Printf("Size of the compressed file: %d bytes (%s in %v)\n", compressionStats.CompressedBytes, COMPRESSION, compressionStats.CompressionTime)
 	// Maximum size of a chunk respected to the bandwidth
-	fmt.Printf("Maximum size of a chunk: %d txs\n", TXN_SIZE/(SizeOfTheFile()/UPLOAD_BANDWIDTH))
+	fmt.Printf("Maximum size of a chunk: %d txs\n", TXN_SIZE*UPLOAD_BANDWIDTH/SizeOfTheFile())
 	// Size of each coded chunk in bytes
-	fmt.Printf("Size of each coded chunk: %d bytes\n", SizeOfTheFile()/K)
+	fmt.Printf("Size of each coded chunk: %d bytes\n", compressionStats.CompressedBytes/K)
 	// Maximum number of coded chunk respected to the bandwidth
-	fmt.Printf("Maximum number of coded chunks: %d\n", BANDWIDTH/(SizeOfTheFile()/K))
-	COUNTER = BANDWIDTH / (SizeOfTheFile() / K)
-	fmt.Println("COUNTER:", COUNTER)
+	fmt.Printf("Maximum number of coded chunks: %d\n", BANDWIDTH*K/compressionStats.CompressedBytes)
 	faultyNodes := faultyNodesDriver(faultyNodesCount)
 	// print the faulty nodes
 	fmt.Println("Faulty nodes:", faultyNodes)
@@ -7,6 +7,7 @@ import (
 	"io"
 	"log"
 	"net"
+	"time"
 )
 
 func InitializeAdversary(faultyNode []int) {
@@ -17,7 +18,8 @@ func InitializeAdversary(faultyNode []int) {
 
 func InitializeNetwork(numNodes int, startingPort int) *Network {
 	network := &Network{
-		Nodes: make(map[int]*Node),
+		Nodes:  make(map[int]*Node),
+		Blocks: make(map[int]*Block),
 	}
 	for i := 0; i < Nodes; i++ {
 		address := fmt.Sprintf("localhost:%d", startingPort+i)
@@ -32,15 +34,17 @@ func InitializeNetwork(numNodes int, startingPort int) *Network {
 			byzantine = true
 		}
 		node := &Node{
-			ID:                i,
-			Address:           address,
-			Listener:          listener,
-			Peers:             make(map[int]string),
-			Blockchain:        make([]*Block, 0),
-			IsByzantine:       byzantine,
-			BlackList:         make(map[int]bool),
-			ReceivedChunks:    make(map[int]Chunk),
-			BandwidthOverhead: 0,
+			ID:             i,
+			Address:        address,
+			Listener:       listener,
+			Peers:          make(map[int]string),
+			Blockchain:     make([]*Block, 0),
+			Network:        network,
+			IsByzantine:    byzantine,
+			BlackList:      make(map[int]bool),
+			ReceivedChunks: make(map[int]Chunk),
+			Upload:         NewTokenBucket(UPLOAD_BANDWIDTH, BUFFER_SIZE),
+			Download:       NewTokenBucket(BANDWIDTH, BUFFER_SIZE),
 		}
 		network.Nodes[i] = node
 	}
@@ -102,12 +106,25 @@ func (n *Node) handleMessage(message Message, conn net.Conn) {
 	}
 }
 
+// servedBlock returns the block with the ID, generating it on first use so
+// every peer codes and commits the same bytes
+func (network *Network) servedBlock(blockID int, generate func(int) *Block) *Block {
+	network.lock.Lock()
+	defer network.lock.Unlock()
+	if block, ok := network.Blocks[blockID]; ok {
+		return block
+	}
+	block := generate(blockID)
+	network.Blocks[blockID] = block
+	return block
+}
+
 func (n *Node) readResponse(conn net.Conn, connectedPeer int) bool {
 	var buf [BUFFER_SIZE]byte
 	var accumulatedData bytes.Buffer
 
 	for {
-		// conn.SetReadDeadline(time.Now().Add(10 * time.Second)) // Reset deadline before each read
+		conn.SetReadDeadline(time.Now().Add(10 * time.Second)) // Reset deadline before each read
 		length, err := conn.Read(buf[:])
 		if err != nil {
 			if err != io.EOF {
@@ -143,6 +160,7 @@ func (n *Node) readResponse(conn net.Conn, connectedPeer int) bool {
 					log.Printf("Node %d error unmarshaling chunk response: %v", n.ID, err)
 					continue
 				}
+				n.Metrics.recordReceived(connectedPeer, responseTraffic(&response, len(fullMessage)))
 				n.handleChunkResponse(&response)
 				break
 			}
@@ -19,7 +19,7 @@ func (n *Node) Start() {
 				log.Fatalf("Error accepting connection: %v", err)
 				continue
 			}
-			go n.handleIncomingConnection(conn)
+			go n.handleIncomingConnection(n.shape(conn))
 		}
 	}()
 }
@@ -33,19 +33,20 @@ func (n *Node) sendChunkRequest(blockID int) {
 		TotalChunks:      0,
 		SuccessfulChunks: 0,
 		FailedChunks:     0,
+		Sent:             make(map[int]Traffic),
+		Received:         make(map[int]Traffic),
+		Compression:      compressionStats,
 	}
 	wg := sync.WaitGroup{}
 
 	for i := 1; i < Nodes; i++ {
 		wg.Add(1)
-		if (i%COUNTER == 0) && (i != 0) {
-			time.Sleep(1 * time.Second)
-		}
 		go func(i int) {
 			defer wg.Done()
 			request := &ChunkRequest{
-				NodeID:  n.ID,
-				BlockID: blockID,
+				NodeID:   n.ID,
+				BlockID:  blockID,
+				ChunkIDs: peerChunkIDs(i),
 			}
 
 			message := &Message{
@@ -61,7 +62,7 @@ func (n *Node) sendChunkRequest(blockID int) {
 			}
 			selectedPeer := n.Peers[i]
 			fmt.Println("Sending request to peer", i)
-			conn, err := net.Dial("tcp", selectedPeer)
+			conn, err := n.dial(selectedPeer)
 			if err != nil {
 				log.Fatalf("Error connecting to peer: %v", err)
 			}
@@ -71,8 +72,7 @@ func (n *Node) sendChunkRequest(blockID int) {
 				log.Printf("Error writing response to connection: %v", err)
 				return
 			}
-			readDeadline := time.Now().Add(10 * time.Second)
-			conn.SetReadDeadline(readDeadline)
+			n.Metrics.recordSent(i, requestTraffic(len(requestData)))
 			if !n.readResponse(conn, i) {
 				fmt.Println("Failed to read response from peer", i)
 			}
@@ -85,24 +85,52 @@ func (n *Node) sendChunkRequest(blockID int) {
 
 }
 
+// peerChunkIDs spreads the chunks over the peers, peer i serving the
+// SHARDS_PER_PEER chunks from i*SHARDS_PER_PEER on
+func peerChunkIDs(peer int) []int {
+	ids := make([]int, SHARDS_PER_PEER)
+	for t := range ids {
+		ids[t] = (peer*SHARDS_PER_PEER + t) % N
+	}
+	return ids
+}
+
 func (n *Node) processChunkRequest(request *ChunkRequest, conn net.Conn) {
-	block := n.generateBlockForRequest(request.BlockID)
-	blockBytes, _ := json.Marshal(block)
+	block := n.Network.servedBlock(request.BlockID, n.generateBlockForRequest)
+	blockBytes := MarshalBlock(block)
+	blockBytes, err := Compress(blockBytes)
+	if err != nil {
+		log.Printf("Error compressing block: %v", err)
+		return
+	}
 	// fmt.Println("Size of block in bytes: ", len(blockBytes))
 	chunks := GenerateCodedChunks(blockBytes)
-	rootHash, proofs := CreateVectorCommitment(chunks)
+	rootHash, opener := CreateVectorCommitment(chunks)
 	if n.IsByzantine {
 		// DOINT NOTHING
 	} else {
-		for i := range chunks {
-			chunks[i].Proof = *proofs[i]
-		}
-
 		response := ChunkResponse{
 			NodeID:     n.ID,
-			Chunk:      &chunks[n.ID],
+			ChunkIDs:   request.ChunkIDs,
 			Commitment: rootHash,
 		}
+		for _, id := range request.ChunkIDs {
+			if id < 0 || id >= len(chunks) {
+				log.Printf("Node %d asked for chunk %d out of range", request.NodeID, id)
+				return
+			}
+			response.Chunks = append(response.Chunks, chunks[id])
+		}
+		var err error
+		if len(request.ChunkIDs) == 1 {
+			response.Chunks[0].Proof, err = opener.Open(request.ChunkIDs[0])
+		} else {
+			response.Proof, err = opener.OpenMany(request.ChunkIDs)
+		}
+		if err != nil {
+			log.Printf("Error opening chunks %v: %v", request.ChunkIDs, err)
+			return
+		}
 
 		var message = &Message{
 			From:    n.ID,
@@ -118,13 +146,7 @@ func (n *Node) processChunkRequest(request *ChunkRequest, conn net.Conn) {
 		}
 		fmt.Println("Sending response to node", request.NodeID)
 		time.Sleep(NETWORK_DELAY)
-		// Upload bandwidth limitation:
-		bandwidthLimit := len(responseBytes) / UPLOAD_BANDWIDTH
-		if n.BandwidthOverhead == 0 {
-			n.BandwidthOverhead += bandwidthLimit
-		}
-		fmt.Println("Bandwidth limit: ", bandwidthLimit)
-		// time.Sleep(time.Duration(bandwidthLimit) * time.Second)
+		// Upload bandwidth is limited by the node's token bucket
 		_, err = conn.Write(responseBytes)
 		if err != nil {
 			log.Printf("Error writing response to connection: %v", err)
@@ -136,7 +158,7 @@ func (n *Node) processChunkRequest(request *ChunkRequest, conn net.Conn) {
 
 func (n *Node) generateBlockForRequest(blockID int) *Block {
 
-	txs := GenerateTransactions(TXN_SIZE)
+	txs := BlockTransactions()
 	block := &Block{
 		ID:           blockID,
 		PreviousHash: "",
@@ -155,27 +177,56 @@ func (n *Node) generateBlockForRequest(blockID int) *Block {
 
 func (n *Node) handleChunkResponse(response *ChunkResponse) {
 
-	n.Metrics.TotalChunks++
-	// fmt.Println("response commtiment: ", response.Commitment, "proof", response.Chunk.Proof)
-	if VerifyChunk(response.Commitment, *response.Chunk, &response.Chunk.Proof, n) {
-		n.Metrics.SuccessfulChunks++
-		n.ReceivedChunks[response.NodeID] = *response.Chunk
-		fmt.Println("Chunk integrated successfully.")
+	n.Metrics.TotalChunks += len(response.Chunks)
+	// fmt.Println("response commtiment: ", response.Commitment, "proof", response.Proof)
+	var verified bool
+	switch {
+	case len(response.Chunks) != len(response.ChunkIDs) || len(response.Chunks) == 0:
+		verified = false
+	case len(response.Chunks) == 1:
+		verified = VerifyChunk(response.Commitment, response.Chunks[0], response.ChunkIDs[0], n)
+	default:
+		verified = VerifyChunks(response.Commitment, response.Chunks, response.ChunkIDs, response.Proof, n)
+	}
+	if verified {
+		n.Metrics.SuccessfulChunks += len(response.Chunks)
+		for i, id := range response.ChunkIDs {
+			n.ReceivedChunks[id] = response.Chunks[i]
+		}
+		fmt.Println("Chunks integrated successfully:", response.ChunkIDs)
 	} else {
-		n.Metrics.FailedChunks++
-		fmt.Println("Failed to verify chunk.")
+		n.Metrics.FailedChunks += len(response.Chunks)
+		fmt.Println("Failed to verify chunks from node", response.NodeID)
 	}
-	if len(n.ReceivedChunks) == K {
+	if len(n.ReceivedChunks) >= K {
 		fmt.Println("Enough chunks recieved, size of chunk is", len(n.ReceivedChunks[0].Data))
 		decodedMessage, err := Decode(n.ReceivedChunks)
 		if err != nil {
 			log.Fatalf("Error decoding message: %v", err)
 		}
-		fmt.Println("Decoded message:", len(decodedMessage))
+		for _, chunk := range n.ReceivedChunks {
+			n.Metrics.ShardBytes = len(chunk.Data)
+			break
+		}
+		startTime := time.Now()
+		blockBytes, err := Decompress([]byte(decodedMessage))
+		if err != nil {
+			log.Fatalf("Error decompressing message: %v", err)
+		}
+		n.Metrics.Compression.DecompressionTime = time.Since(startTime)
+		block, err := UnmarshalBlock(blockBytes)
+		if err != nil {
+			log.Fatalf("Error unmarshaling block: %v", err)
+		}
+		if GenerateBlockHash(*block) != block.Hash {
+			log.Fatalf("Decoded block %d does not match its hash", block.ID)
+		}
+		n.Metrics.TotalTransactions = len(block.Transactions)
+		fmt.Println("Decoded message:", len(blockBytes))
 		n.Metrics.EndTime = time.Now()
 		n.Metrics.TotalDuration = n.Metrics.EndTime.Sub(n.Metrics.StartTime)
-		// n.Metrics.TotalDuration += time.Duration(n.BandwidthOverhead) * time.Second
 		fmt.Printf("Sync Metrics for Node %d: %+v\n", n.ID, n.Metrics)
+		n.Metrics.PrintTraffic()
 		panic("Sync complete")
 		// size of message in byte
 	}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/cloudflare/circl/group"
+)
+
+// ###################################
+// Lists of Pedersen commitments over ristretto255
+//
+// Every chunk gets its own commitment C_i = H(chunk_i)*G + r_i*H and the
+// scheme commits to the chunks with the list of them. This is not a vector
+// commitment: it grows with N, 32 bytes per chunk, where Merkle and KZG stay
+// constant, while the proof of a chunk is just its randomness r_i.
+//
+// The commitments add up, so several chunks open with one scalar
+// sum w_i*r_i. The weights w_i hash the commitment and the opened chunks;
+// with plain sums a sender could look for other chunks with the same
+// H(chunk) total.
+
+const (
+	pedersenListDST   = "thesis_simulation-chunk-Pedersen-v1"
+	pedersenListSeed  = "coded chunk commitments"
+	pedersenWeightDST = "thesis_simulation-chunk-Pedersen-weights-v1"
+)
+
+// PedersenCommitmentList holds the generators, H comes out of a
+// hash-to-curve so nobody knows its discrete log
+type PedersenCommitmentList struct {
+	group group.Group
+	H     group.Element
+}
+
+func NewPedersenCommitmentList() (*PedersenCommitmentList, error) {
+	g := group.Ristretto255
+	H := g.HashToElement([]byte(pedersenListSeed), []byte(pedersenListDST))
+	if H.IsIdentity() {
+		return nil, errors.New("pedersen generator is the identity")
+	}
+	return &PedersenCommitmentList{group: g, H: H}, nil
+}
+
+func (pc *PedersenCommitmentList) Name() string { return "pedersen-list" }
+
+// message maps a chunk to the scalar it commits to
+func (pc *PedersenCommitmentList) message(data []byte) group.Scalar {
+	return pc.group.HashToScalar(data, []byte(pedersenListDST))
+}
+
+// commit computes m*G + r*H
+func (pc *PedersenCommitmentList) commit(m, r group.Scalar) group.Element {
+	commitment := pc.group.NewElement().MulGen(m)
+	return commitment.Add(commitment, pc.group.NewElement().Mul(pc.H, r))
+}
+
+// weights derives the weight of each opened chunk from the commitment, the
+// indices and the chunk scalars
+func (pc *PedersenCommitmentList) weights(commitment []byte, indices []int, messages []group.Scalar) []group.Scalar {
+	transcript := append([]byte(nil), commitment...)
+	for i, index := range indices {
+		m, err := messages[i].MarshalBinary()
+		if err != nil {
+			panic(err)
+		}
+		transcript = binary.AppendUvarint(transcript, uint64(index))
+		transcript = append(transcript, m...)
+	}
+	weights := make([]group.Scalar, len(indices))
+	for i := range weights {
+		weights[i] = pc.group.HashToScalar(binary.AppendUvarint(transcript, uint64(i)), []byte(pedersenWeightDST))
+	}
+	return weights
+}
+
+// pedersenOpener keeps the scalar and randomness of every chunk
+type pedersenOpener struct {
+	pc         *PedersenCommitmentList
+	commitment []byte
+	messages   []group.Scalar
+	randomness []group.Scalar
+}
+
+func (pc *PedersenCommitmentList) Commit(data [][]byte) ([]byte, VectorOpener, error) {
+	var commitment bytes.Buffer
+	opener := &pedersenOpener{
+		pc:         pc,
+		messages:   make([]group.Scalar, len(data)),
+		randomness: make([]group.Scalar, len(data)),
+	}
+	for i, chunk := range data {
+		opener.messages[i] = pc.message(chunk)
+		opener.randomness[i] = pc.group.RandomScalar(rand.Reader)
+		c, err := pc.commit(opener.messages[i], opener.randomness[i]).MarshalBinaryCompress()
+		if err != nil {
+			return nil, nil, err
+		}
+		commitment.Write(c)
+	}
+	opener.commitment = commitment.Bytes()
+	return opener.commitment, opener, nil
+}
+
+func (o *pedersenOpener) Open(index int) ([]byte, error) {
+	if index < 0 || index >= len(o.randomness) {
+		return nil, fmt.Errorf("index %d out of range", index)
+	}
+	return o.randomness[index].MarshalBinary()
+}
+
+// OpenMany returns the weighted sum of the randomness of the indices
+func (o *pedersenOpener) OpenMany(indices []int) ([]byte, error) {
+	if err := checkIndices(indices, len(o.randomness)); err != nil {
+		return nil, err
+	}
+	messages := make([]group.Scalar, len(indices))
+	for i, index := range indices {
+		messages[i] = o.messages[index]
+	}
+	r := o.pc.group.NewScalar()
+	for i, w := range o.pc.weights(o.commitment, indices, messages) {
+		r.Add(r, w.Mul(w, o.randomness[indices[i]]))
+	}
+	return r.MarshalBinary()
+}
+
+// element parses the index-th commitment
+func (pc *PedersenCommitmentList) element(commitment []byte, index int) (group.Element, error) {
+	size := int(pc.group.Params().CompressedElementLength)
+	if index < 0 || (index+1)*size > len(commitment) {
+		return nil, fmt.Errorf("index %d out of range", index)
+	}
+	c := pc.group.NewElement()
+	if err := c.UnmarshalBinary(commitment[index*size : (index+1)*size]); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (pc *PedersenCommitmentList) Verify(commitment []byte, index int, data []byte, proof []byte) error {
+	expected, err := pc.element(commitment, index)
+	if err != nil {
+		return err
+	}
+	r := pc.group.NewScalar()
+	if err := r.UnmarshalBinary(proof); err != nil {
+		return err
+	}
+	if !pc.commit(pc.message(data), r).IsEqual(expected) {
+		return errors.New("pedersen opening does not match the commitment")
+	}
+	return nil
+}
+
+// VerifyMany checks sum w_i*C_i == (sum w_i*H(chunk_i))*G + r*H
+func (pc *PedersenCommitmentList) VerifyMany(commitment []byte, indices []int, data [][]byte, proof []byte) error {
+	if len(indices) != len(data) {
+		return fmt.Errorf("%d indices for %d chunks", len(indices), len(data))
+	}
+	size := int(pc.group.Params().CompressedElementLength)
+	if err := checkIndices(indices, len(commitment)/size); err != nil {
+		return err
+	}
+	messages := make([]group.Scalar, len(data))
+	for i, chunk := range data {
+		messages[i] = pc.message(chunk)
+	}
+	expected := pc.group.NewElement()
+	m := pc.group.NewScalar()
+	for i, w := range pc.weights(commitment, indices, messages) {
+		c, err := pc.element(commitment, indices[i])
+		if err != nil {
+			return err
+		}
+		expected.Add(expected, c.Mul(c, w))
+		m.Add(m, w.Mul(w, messages[i]))
+	}
+	r := pc.group.NewScalar()
+	if err := r.UnmarshalBinary(proof); err != nil {
+		return err
+	}
+	if !pc.commit(m, r).IsEqual(expected) {
+		return errors.New("pedersen opening does not match the commitment")
+	}
+	return nil
+}
@@ -2,12 +2,16 @@ package main
 
 import (
 	"bytes"
+	"encoding/binary"
 	"fmt"
 
 	"github.com/klauspost/reedsolomon"
-	"github.com/tendermint/tendermint/crypto/merkle"
 )
 
+// The data is coded with its length in front, so the length is committed
+// along with the chunks and Decode can cut off the shard padding exactly
+const lengthPrefixSize = 8
+
 // GenerateCodedChunks generates n coded chunks from the original data
 func GenerateCodedChunks(data []byte) []Chunk {
 	enc, err := reedsolomon.New(K, N-K)
@@ -15,8 +19,11 @@ func GenerateCodedChunks(data []byte) []Chunk {
 		panic(err)
 	}
 
+	prefixed := binary.BigEndian.AppendUint64(make([]byte, 0, lengthPrefixSize+len(data)), uint64(len(data)))
+	prefixed = append(prefixed, data...)
+
 	// Split data into k data shards
-	shards, err := enc.Split(data)
+	shards, err := enc.Split(prefixed)
 	if err != nil {
 		panic(err)
 	}
@@ -31,12 +38,14 @@ func GenerateCodedChunks(data []byte) []Chunk {
 	// Convert shards to chunks
 	chunks := make([]Chunk, N)
 	for i := 0; i < N; i++ {
-		chunks[i] = Chunk{Data: shards[i], Proof: merkle.Proof{}}
+		chunks[i] = Chunk{Data: shards[i]}
 	}
 
 	return chunks
 }
 
+// Decode reconstructs the data from any K chunks and cuts it back to the
+// length coded in front of it, dropping the padding of the last data shard
 func Decode(chunks map[int]Chunk) (string, error) {
 	enc, err := reedsolomon.New(K, N-K)
 	if err != nil {
@@ -65,9 +74,14 @@ func Decode(chunks map[int]Chunk) (string, error) {
 		return "", fmt.Errorf("failed to join shards: %v", err)
 	}
 
-	// Convert buffer to string and trim null padding
 	decodedData := buf.Bytes()
-	trimmedData := bytes.TrimRight(decodedData, "\x00")
-
-	return string(trimmedData), nil
+	if len(decodedData) < lengthPrefixSize {
+		return "", fmt.Errorf("decoded %d bytes, too short for the length", len(decodedData))
+	}
+	length := binary.BigEndian.Uint64(decodedData)
+	decodedData = decodedData[lengthPrefixSize:]
+	if length > uint64(len(decodedData)) {
+		return "", fmt.Errorf("decoded %d bytes, but the data claims %d", len(decodedData), length)
+	}
+	return string(decodedData[:length]), nil
 }
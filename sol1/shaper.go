@@ -0,0 +1,95 @@
+package main
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// ###################################
+// Traffic shaping
+//
+// Every node owns an upload and a download token bucket shared by all its
+// connections, so concurrent transfers split the node's bandwidth between
+// them instead of each getting the full rate.
+
+// TokenBucket lets bytes through at rate bytes per second with bursts of up
+// to burst bytes
+type TokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  int
+	tokens float64
+	last   time.Time
+}
+
+func NewTokenBucket(rate, burst int) *TokenBucket {
+	return &TokenBucket{rate: float64(rate), burst: burst, tokens: float64(burst), last: time.Now()}
+}
+
+// Take blocks until n bytes may pass. Callers reserve their tokens in turn
+// and sleep off the debt, so waiting transfers are served in order.
+func (b *TokenBucket) Take(n int) {
+	b.mu.Lock()
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > float64(b.burst) {
+		b.tokens = float64(b.burst)
+	}
+	b.last = now
+	b.tokens -= float64(n)
+	wait := time.Duration(-b.tokens / b.rate * float64(time.Second))
+	b.mu.Unlock()
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+}
+
+// ShapedConn passes the bytes of a connection through the node's buckets
+type ShapedConn struct {
+	net.Conn
+	upload   *TokenBucket
+	download *TokenBucket
+}
+
+// Read charges the download bucket for what arrived, at most a burst per
+// call so one connection cannot hold the bucket
+func (c *ShapedConn) Read(p []byte) (int, error) {
+	if len(p) > c.download.burst {
+		p = p[:c.download.burst]
+	}
+	n, err := c.Conn.Read(p)
+	if n > 0 {
+		c.download.Take(n)
+	}
+	return n, err
+}
+
+// Write sends p a burst at a time, charging the upload bucket before each
+func (c *ShapedConn) Write(p []byte) (int, error) {
+	written := 0
+	for written < len(p) {
+		end := min(written+c.upload.burst, len(p))
+		c.upload.Take(end - written)
+		n, err := c.Conn.Write(p[written:end])
+		written += n
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// shape wraps a connection of the node
+func (n *Node) shape(conn net.Conn) net.Conn {
+	return &ShapedConn{Conn: conn, upload: n.Upload, download: n.Download}
+}
+
+// dial opens a shaped connection to a peer
+func (n *Node) dial(address string) (net.Conn, error) {
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	return n.shape(conn), nil
+}
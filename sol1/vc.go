@@ -1,33 +1,269 @@
 package main
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+	"sort"
 	"time"
 
 	"github.com/tendermint/tendermint/crypto/merkle"
+	tmcrypto "github.com/tendermint/tendermint/proto/tendermint/crypto"
 )
 
 // ###################################
 
-func CreateVectorCommitment(chunks []Chunk) ([]byte, []*merkle.Proof) {
-	leaves := make([][]byte, len(chunks))
-	for i, chunk := range chunks {
-		leaves[i] = chunk.Data
+// VectorCommitment commits to the vector of coded chunks and opens single
+// positions or several at once. Proofs travel as opaque bytes, so the sync
+// protocol does not depend on the scheme.
+type VectorCommitment interface {
+	Name() string
+	// Commit commits to the chunk data and returns an opener for its positions
+	Commit(data [][]byte) ([]byte, VectorOpener, error)
+	// Verify checks a serialized proof that data sits at index
+	Verify(commitment []byte, index int, data []byte, proof []byte) error
+	// VerifyMany checks one proof that data[j] sits at indices[j] for every j
+	VerifyMany(commitment []byte, indices []int, data [][]byte, proof []byte) error
+}
+
+// VectorOpener produces the serialized proof of positions of a commitment.
+// OpenMany proves several positions with one proof, smaller than the
+// single proofs put together.
+type VectorOpener interface {
+	Open(index int) ([]byte, error)
+	OpenMany(indices []int) ([]byte, error)
+}
+
+// checkIndices rejects empty, out of range and repeated positions
+func checkIndices(indices []int, n int) error {
+	if len(indices) == 0 {
+		return errors.New("no index to open")
 	}
+	seen := make(map[int]bool, len(indices))
+	for _, index := range indices {
+		if index < 0 || index >= n {
+			return fmt.Errorf("index %d out of range", index)
+		}
+		if seen[index] {
+			return fmt.Errorf("index %d repeated", index)
+		}
+		seen[index] = true
+	}
+	return nil
+}
 
-	rootHash, proofs := merkle.ProofsFromByteSlices(leaves)
-	return rootHash, proofs
+// vectorCommitment is the scheme of the run, shared by all nodes
+var vectorCommitment VectorCommitment
+
+// NewVectorCommitment returns the named scheme for vectors of up to n chunks
+func NewVectorCommitment(name string, n int) (VectorCommitment, error) {
+	switch name {
+	case "merkle":
+		return MerkleCommitment{}, nil
+	case "kzg":
+		// Local trusted setup, every node of the run shares it
+		return NewKZGSetup(n), nil
+	case "pedersen-list":
+		return NewPedersenCommitmentList()
+	}
+	return nil, fmt.Errorf("unknown vector commitment %q, expected merkle, kzg or pedersen-list", name)
 }
 
-func VerifyChunk(rootHash []byte, chunk Chunk, proof *merkle.Proof, node *Node) bool {
-	// Size of proof and commitment in byte
-	// proofSize := reflect.TypeOf(*proof).Size()
-	// commitmentSize := reflect.TypeOf(rootHash).Size()
-	// fmt.Println("Size of proof and commitment in byte: ", proofSize, commitmentSize)
+func CreateVectorCommitment(chunks []Chunk) ([]byte, VectorOpener) {
+	data := make([][]byte, len(chunks))
+	for i, chunk := range chunks {
+		data[i] = chunk.Data
+	}
+
+	commitment, opener, err := vectorCommitment.Commit(data)
+	if err != nil {
+		panic(err)
+	}
+	return commitment, opener
+}
 
+func VerifyChunk(commitment []byte, chunk Chunk, index int, node *Node) bool {
 	// Capture the time for verification
 	start := time.Now()
-	err := proof.Verify(rootHash, chunk.Data)
-	// fmt.Println("Time taken for verification: ")
+	err := vectorCommitment.Verify(commitment, index, chunk.Data, chunk.Proof)
+	node.Metrics.VerificationTime += time.Since(start)
+	return err == nil
+}
+
+// VerifyChunks checks chunks[j] at indices[j] against one batch proof
+func VerifyChunks(commitment []byte, chunks []Chunk, indices []int, proof []byte, node *Node) bool {
+	start := time.Now()
+	data := make([][]byte, len(chunks))
+	for i, chunk := range chunks {
+		data[i] = chunk.Data
+	}
+	err := vectorCommitment.VerifyMany(commitment, indices, data, proof)
 	node.Metrics.VerificationTime += time.Since(start)
 	return err == nil
 }
+
+// ###################################
+
+// MerkleCommitment is a tendermint merkle tree over the chunks. Proofs are
+// the protobuf encoding of merkle.Proof and grow with log N. A multiproof
+// sends each sibling hash once and leaves out the ones the verifier can
+// compute from the other opened chunks.
+type MerkleCommitment struct{}
+
+// merkleOpener keeps the single proofs and the leaf hashes for multiproofs
+type merkleOpener struct {
+	proofs []*merkle.Proof
+	leaves [][]byte
+}
+
+func (MerkleCommitment) Name() string { return "merkle" }
+
+func (MerkleCommitment) Commit(data [][]byte) ([]byte, VectorOpener, error) {
+	rootHash, proofs := merkle.ProofsFromByteSlices(data)
+	leaves := make([][]byte, len(data))
+	for i, chunk := range data {
+		leaves[i] = leafHash(chunk)
+	}
+	return rootHash, &merkleOpener{proofs: proofs, leaves: leaves}, nil
+}
+
+func (o *merkleOpener) Open(index int) ([]byte, error) {
+	if index < 0 || index >= len(o.proofs) {
+		return nil, fmt.Errorf("index %d out of range", index)
+	}
+	return o.proofs[index].ToProto().Marshal()
+}
+
+func (MerkleCommitment) Verify(commitment []byte, index int, data []byte, proof []byte) error {
+	var pb tmcrypto.Proof
+	if err := pb.Unmarshal(proof); err != nil {
+		return err
+	}
+	p, err := merkle.ProofFromProto(&pb)
+	if err != nil {
+		return err
+	}
+	if p.Index != int64(index) {
+		return fmt.Errorf("proof opens index %d, expected %d", p.Index, index)
+	}
+	return p.Verify(commitment, data)
+}
+
+// Tendermint hashes leaves and inner nodes as in RFC 6962 and splits n
+// leaves at the largest power of two below n. The multiproof rebuilds the
+// same tree, so its root is the one of merkle.ProofsFromByteSlices.
+
+func leafHash(leaf []byte) []byte {
+	h := sha256.Sum256(append([]byte{0}, leaf...))
+	return h[:]
+}
+
+func innerHash(left, right []byte) []byte {
+	h := sha256.Sum256(append(append([]byte{1}, left...), right...))
+	return h[:]
+}
+
+func splitPoint(n int) int {
+	return 1 << (bits.Len(uint(n-1)) - 1)
+}
+
+// subtreeHash hashes the leaves in [lo, hi)
+func subtreeHash(leaves [][]byte, lo, hi int) []byte {
+	if hi-lo == 1 {
+		return leaves[lo]
+	}
+	mid := lo + splitPoint(hi-lo)
+	return innerHash(subtreeHash(leaves, lo, mid), subtreeHash(leaves, mid, hi))
+}
+
+// opens reports whether a sorted index falls in [lo, hi)
+func opens(sorted []int, lo, hi int) bool {
+	i := sort.SearchInts(sorted, lo)
+	return i < len(sorted) && sorted[i] < hi
+}
+
+// appendSiblings walks [lo, hi) depth first and appends the hash of every
+// subtree with no opened leaf
+func (o *merkleOpener) appendSiblings(proof []byte, sorted []int, lo, hi int) []byte {
+	if !opens(sorted, lo, hi) {
+		return append(proof, subtreeHash(o.leaves, lo, hi)...)
+	}
+	if hi-lo == 1 {
+		return proof
+	}
+	mid := lo + splitPoint(hi-lo)
+	proof = o.appendSiblings(proof, sorted, lo, mid)
+	return o.appendSiblings(proof, sorted, mid, hi)
+}
+
+// OpenMany returns the number of leaves followed by the sibling hashes in
+// depth first order
+func (o *merkleOpener) OpenMany(indices []int) ([]byte, error) {
+	if err := checkIndices(indices, len(o.leaves)); err != nil {
+		return nil, err
+	}
+	sorted := append([]int(nil), indices...)
+	sort.Ints(sorted)
+	proof := binary.AppendUvarint(nil, uint64(len(o.leaves)))
+	return o.appendSiblings(proof, sorted, 0, len(o.leaves)), nil
+}
+
+// rebuildRoot mirrors appendSiblings, taking sibling hashes off the proof
+func rebuildRoot(leaves map[int][]byte, sorted []int, lo, hi int, proof *bytes.Reader) ([]byte, error) {
+	if !opens(sorted, lo, hi) {
+		h := make([]byte, sha256.Size)
+		if _, err := io.ReadFull(proof, h); err != nil {
+			return nil, errors.New("merkle multiproof too short")
+		}
+		return h, nil
+	}
+	if hi-lo == 1 {
+		return leaves[lo], nil
+	}
+	mid := lo + splitPoint(hi-lo)
+	left, err := rebuildRoot(leaves, sorted, lo, mid, proof)
+	if err != nil {
+		return nil, err
+	}
+	right, err := rebuildRoot(leaves, sorted, mid, hi, proof)
+	if err != nil {
+		return nil, err
+	}
+	return innerHash(left, right), nil
+}
+
+func (MerkleCommitment) VerifyMany(commitment []byte, indices []int, data [][]byte, proof []byte) error {
+	if len(indices) != len(data) {
+		return fmt.Errorf("%d indices for %d chunks", len(indices), len(data))
+	}
+	r := bytes.NewReader(proof)
+	total, err := binary.ReadUvarint(r)
+	if err != nil || total == 0 || total > math.MaxInt32 {
+		return errors.New("bad merkle multiproof header")
+	}
+	if err := checkIndices(indices, int(total)); err != nil {
+		return err
+	}
+	leaves := make(map[int][]byte, len(indices))
+	for i, index := range indices {
+		leaves[index] = leafHash(data[i])
+	}
+	sorted := append([]int(nil), indices...)
+	sort.Ints(sorted)
+	root, err := rebuildRoot(leaves, sorted, 0, int(total), r)
+	if err != nil {
+		return err
+	}
+	if r.Len() != 0 {
+		return errors.New("merkle multiproof too long")
+	}
+	if !bytes.Equal(root, commitment) {
+		return errors.New("merkle multiproof does not match the root")
+	}
+	return nil
+}